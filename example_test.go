@@ -15,6 +15,7 @@
 package spannerbench_test
 
 import (
+	"fmt"
 	"time"
 
 	"cloud.google.com/go/spanner"
@@ -35,16 +36,41 @@ func Example() {
 	}
 
 	benchmarkReadWrite := func(b *spannerbench.B) {
-		b.N(50) // Runs for 50 times.
+		b.N(50)          // Runs for 50 times.
+		b.Concurrency(5) // Runs 5 transactions in parallel.
 		b.Run(func(tx *spanner.ReadWriteTransaction) error {
 			// TODO: Use tx to run queries.
 			return nil
 		})
 	}
 
-	spannerbench.Benchmark(
+	results := spannerbench.Benchmark(
 		"projects/YOUR_PROJECT/instances/YOUR_INSTANCE/databases/YOUR_DB",
 		benchmarkReadOnly,
 		benchmarkReadWrite,
 	)
+	for _, r := range results {
+		fmt.Printf("%v: p99=%v errors=%v\n", r.Name, r.Percentile(99), r.Errors)
+	}
+}
+
+func benchmarkLimits(b *spannerbench.B) {
+	b.N(50)
+	for _, limit := range []int{1, 10, 100} {
+		limit := limit
+		b.Sub(fmt.Sprintf("limit=%d", limit), func(b *spannerbench.B) {
+			b.RunReadOnly(func(tx *spanner.ReadOnlyTransaction) error {
+				// TODO: Use tx to query with the limit.
+				return nil
+			})
+		})
+	}
+}
+
+func ExampleB_Sub() {
+	// Results are named benchmarkLimits/limit=1 and so on.
+	spannerbench.Benchmark(
+		"projects/YOUR_PROJECT/instances/YOUR_INSTANCE/databases/YOUR_DB",
+		benchmarkLimits,
+	)
 }
@@ -16,6 +16,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	spannerbench "github.com/cloudspannerecosystem/spanner-bench"
@@ -23,8 +25,17 @@ import (
 )
 
 func main() {
-	spannerbench.Benchmark(
+	// Use -bench to run some of the benchmarks.
+	var opts spannerbench.Options
+	opts.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	spannerbench.BenchmarkWithOptions(ctx,
 		"projects/YOUR_PROJECT/instances/YOUR_INSTANCE/databases/YOUR_DB",
+		opts,
 		BenchmarkReadOnly,
 		Benchmark,
 	)
@@ -32,8 +43,8 @@ func main() {
 
 func BenchmarkReadOnly(b *spannerbench.B) {
 	b.N(50) // Runs for 100 times.
-	b.RunReadOnly(func(tx *spanner.ReadOnlyTransaction) error {
-		ctx := context.Background()
+	b.Timeout(5 * time.Second)
+	b.RunReadOnlyContext(func(ctx context.Context, tx *spanner.ReadOnlyTransaction) error {
 		it := tx.Query(ctx, spanner.NewStatement("SELECT * FROM tweets LIMIT 10"))
 		defer it.Stop()
 
@@ -50,8 +61,8 @@ func BenchmarkReadOnly(b *spannerbench.B) {
 
 func Benchmark(b *spannerbench.B) {
 	b.N(50) // Runs for 100 times.
-	b.Run(func(tx *spanner.ReadWriteTransaction) error {
-		ctx := context.Background()
+	b.Timeout(5 * time.Second)
+	b.RunContext(func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
 		it := tx.Query(ctx, spanner.NewStatement("SELECT * FROM tweets LIMIT 10"))
 		defer it.Stop()
 
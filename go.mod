@@ -3,8 +3,10 @@ module github.com/cloudspannerecosystem/spanner-bench
 go 1.14
 
 require (
+	cloud.google.com/go v0.62.0
 	cloud.google.com/go/spanner v1.8.0
 	google.golang.org/api v0.30.0
 	google.golang.org/genproto v0.0.0-20200813001606-1ccf2a5ae4fd
+	google.golang.org/grpc v1.31.0
 	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,170 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package batchread reads partitions of a batch
+// read-only transaction in parallel and measures
+// each partition.
+package batchread
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+// Partitioner partitions a read or a query.
+type Partitioner func(ctx context.Context, txn *spanner.BatchReadOnlyTransaction) ([]*spanner.Partition, error)
+
+// Query partitions stmt.
+func Query(stmt spanner.Statement) Partitioner {
+	return func(ctx context.Context, txn *spanner.BatchReadOnlyTransaction) ([]*spanner.Partition, error) {
+		return txn.PartitionQuery(ctx, stmt, spanner.PartitionOptions{})
+	}
+}
+
+// Read partitions a read of columns in keys of table,
+// or of index if it's not empty.
+func Read(table, index string, keys spanner.KeySet, columns []string) Partitioner {
+	return func(ctx context.Context, txn *spanner.BatchReadOnlyTransaction) ([]*spanner.Partition, error) {
+		if index != "" {
+			return txn.PartitionReadUsingIndex(ctx, table, index, keys, columns, spanner.PartitionOptions{})
+		}
+		return txn.PartitionRead(ctx, table, keys, columns, spanner.PartitionOptions{})
+	}
+}
+
+// Result is the outcome of a batch read.
+type Result struct {
+	// Partitions is the number of partitions.
+	Partitions int
+
+	// Elapsed is the latency of reading each
+	// partition in nanoseconds.
+	Elapsed []int64
+
+	// Rows is the number of rows read.
+	Rows int64
+}
+
+// Run starts a batch read-only transaction with tb,
+// partitions it and reads the partitions from up to
+// parallelism goroutines. It returns the first error
+// any partition fails with.
+func Run(ctx context.Context, client *spanner.Client, tb spanner.TimestampBound, partition Partitioner, parallelism int) (*Result, error) {
+	txn, err := client.BatchReadOnlyTransaction(ctx, tb)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Close()
+	defer txn.Cleanup(ctx)
+
+	partitions, err := partition(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	// Stop reading the other partitions once one fails.
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	result := &Result{Partitions: len(partitions)}
+	work := make(chan *spanner.Partition)
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range work {
+				start := time.Now()
+				rows, err := read(readCtx, txn, p)
+				dur := time.Since(start)
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				result.Elapsed = append(result.Elapsed, int64(dur))
+				result.Rows += rows
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range partitions {
+		work <- p
+	}
+	close(work)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+func read(ctx context.Context, txn *spanner.BatchReadOnlyTransaction, p *spanner.Partition) (int64, error) {
+	it := txn.Execute(ctx, p)
+	defer it.Stop()
+
+	var rows int64
+	for {
+		_, err := it.Next()
+		if err == iterator.Done {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows++
+	}
+}
+
+// Stats accumulates the results of batch reads. Add is
+// safe for concurrent use; read the fields once all batch
+// reads are done.
+type Stats struct {
+	mu sync.Mutex
+
+	// Runs is the number of batch reads.
+	Runs int
+
+	// Partitions is the total number of partitions read.
+	Partitions int
+
+	// Elapsed is the latency of each partition
+	// read in nanoseconds.
+	Elapsed []int64
+
+	// Rows is the total number of rows read.
+	Rows int64
+}
+
+// Add adds r to the stats.
+func (s *Stats) Add(r *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Runs++
+	s.Partitions += r.Partitions
+	s.Elapsed = append(s.Elapsed, r.Elapsed...)
+	s.Rows += r.Rows
+}
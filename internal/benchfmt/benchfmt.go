@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package benchfmt writes results in the Go benchmark
+// format, so they can be compared with benchstat.
+package benchfmt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Metric is a value reported for a benchmark, e.g. 1234 ns/op.
+type Metric struct {
+	Value float64
+	Unit  string
+}
+
+// Name returns name as a valid Go benchmark name.
+// It adds the "Benchmark" prefix if missing and
+// replaces spaces since they separate fields.
+func Name(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if !strings.HasPrefix(name, "Benchmark") {
+		name = "Benchmark" + name
+	}
+	return name
+}
+
+// Write writes a benchmark line for n iterations, e.g.
+// "BenchmarkReadOnly  50  312345678 ns/op  3.12 txn/s".
+func Write(w io.Writer, name string, n int, metrics ...Metric) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\t%d", Name(name), n)
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "\t%s %s", formatValue(m.Value), m.Unit)
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatValue formats v like the testing package,
+// with decimals only for small values.
+func formatValue(v float64) string {
+	if v >= 100 || v == 0 {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchfmt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"ReadOnly", "BenchmarkReadOnly"},
+		{"BenchmarkReadOnly", "BenchmarkReadOnly"},
+		{"read users", "Benchmarkread_users"},
+		{"point\tlookup\n", "Benchmarkpoint_lookup_"},
+		{"Reads/limit=10", "BenchmarkReads/limit=10"},
+		{"", "Benchmark"},
+	}
+	for _, tt := range tests {
+		if got := Name(tt.name); got != tt.want {
+			t.Errorf("Name(%q) = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		metrics []Metric
+		want    string
+	}{
+		{"ReadOnly", 50, nil, "BenchmarkReadOnly\t50\n"},
+		{"ReadOnly", 50, []Metric{
+			{Value: 312345678.4, Unit: "ns/op"},
+			{Value: 3.14159, Unit: "txn/s"},
+		}, "BenchmarkReadOnly\t50\t312345678 ns/op\t3.14 txn/s\n"},
+		{"read write", 1, []Metric{
+			{Value: 0, Unit: "rows/op"},
+			{Value: 100, Unit: "ns/op"},
+			{Value: 12.5, Unit: "ns/op"},
+		}, "Benchmarkread_write\t1\t0 rows/op\t100 ns/op\t12.50 ns/op\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := Write(&b, tt.name, tt.n, tt.metrics...); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("Write(%q, %v, %v) wrote %q; want %q", tt.name, tt.n, tt.metrics, got, tt.want)
+		}
+	}
+}
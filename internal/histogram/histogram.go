@@ -81,6 +81,13 @@ func NewHistogram(dur []int64) *Histogram {
 	}
 }
 
+// Buckets returns a copy of the histogram buckets.
+func (h *Histogram) Buckets() []Bucket {
+	buckets := make([]Bucket, len(h.buckets))
+	copy(buckets, h.buckets)
+	return buckets
+}
+
 func (h *Histogram) String() string {
 	max := 0
 	for _, b := range h.buckets {
@@ -0,0 +1,445 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runner repeats benchmark iterations and
+// collects their latencies.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/stats"
+	"google.golang.org/grpc/codes"
+)
+
+// Config configures how iterations are run.
+type Config struct {
+	// N is the number of successful iterations to run.
+	// It is ignored if Duration is set. If Stabilize is
+	// set, N is the minimum number of iterations.
+	N int
+
+	// Stabilize makes Run keep running iterations after
+	// N until the confidence interval of the Percentile
+	// is narrower than Stabilize relative to its value,
+	// e.g. 0.05 for an interval 5% as wide as the median.
+	Stabilize float64
+
+	// MaxN bounds the number of iterations if Stabilize
+	// is set. If zero, 10*N is used.
+	MaxN int
+
+	// Percentile is the percentile Stabilize looks at,
+	// in [0, 100]. If zero, the median is used.
+	Percentile float64
+
+	// Confidence is the confidence level of the interval
+	// reported in Result. If zero, 0.95 is used.
+	Confidence float64
+
+	// Duration makes Run keep running iterations until
+	// the given wall time is spent instead of running
+	// a fixed number of them.
+	Duration time.Duration
+
+	// Concurrency is the number of goroutines running
+	// iterations in parallel. Zero or one runs iterations
+	// sequentially.
+	Concurrency int
+
+	// MaxFailures is the number of failed iterations
+	// tolerated before the run is abandoned. If nil,
+	// 2*N failures are tolerated, or in duration mode,
+	// failures are only bounded by Duration. Zero
+	// tolerates no failures. If negative, failures are
+	// never fatal. Either way, a run that ends without
+	// any successful iteration fails.
+	MaxFailures *int
+
+	// Rate is the target number of iterations started
+	// per second. If set, iterations are started on a
+	// fixed schedule regardless of how long previous
+	// iterations take (open-loop), and latencies are
+	// measured from the scheduled start so queueing
+	// delay is included. Concurrency should be high
+	// enough to keep up with the rate.
+	Rate float64
+
+	// Timeout is the deadline of each iteration.
+	// Zero means iterations have no deadline.
+	Timeout time.Duration
+
+	// WarmUp is the number of untimed iterations
+	// WarmUp runs before the benchmark.
+	WarmUp int
+
+	// WarmUpDuration makes WarmUp run iterations
+	// for the given duration instead of a fixed
+	// number of times.
+	WarmUpDuration time.Duration
+
+	// Setup and Teardown, if set, are called before and
+	// after each iteration outside of the timed region,
+	// with the same timeout. A Setup error fails the
+	// iteration. Teardown is called even if the iteration
+	// fails, and its errors are counted in the Result
+	// without failing the iteration it follows. With Rate,
+	// the time spent in Setup is subtracted from latencies
+	// measured from the scheduled start.
+	Setup    func(ctx context.Context) error
+	Teardown func(ctx context.Context) error
+}
+
+func (c Config) percentile() float64 {
+	if c.Percentile == 0 {
+		return 50
+	}
+	return c.Percentile
+}
+
+func (c Config) confidence() float64 {
+	if c.Confidence == 0 {
+		return 0.95
+	}
+	return c.Confidence
+}
+
+func (c Config) maxN() int {
+	if c.MaxN == 0 {
+		return 10 * c.N
+	}
+	return c.MaxN
+}
+
+// done reports whether enough iterations were run
+// once claimed iterations are started.
+func (c Config) done(claimed int, elapsed []int64) bool {
+	if claimed < c.N {
+		return false
+	}
+	if c.Stabilize <= 0 || claimed >= c.maxN() {
+		return true
+	}
+	if len(elapsed) < c.N {
+		return false // wait for the minimum to complete
+	}
+	lo, hi := stats.PercentileCI(c.percentile(), c.confidence(), elapsed...)
+	return relativeWidth(lo, hi, stats.PercentileInt64(c.percentile(), elapsed...)) <= c.Stabilize
+}
+
+func relativeWidth(lo, hi, estimate int64) float64 {
+	if estimate == 0 {
+		return math.Inf(1)
+	}
+	return float64(hi-lo) / float64(estimate)
+}
+
+func (c Config) maxFailures() int {
+	if c.MaxFailures != nil {
+		return *c.MaxFailures
+	}
+	if c.Duration > 0 {
+		return -1
+	}
+	return 2 * c.N
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	// Elapsed is the latency of each successful
+	// iteration in nanoseconds.
+	Elapsed []int64
+
+	// Errors is the number of failed iterations.
+	Errors int
+
+	// Codes counts failed iterations by their
+	// gRPC status code.
+	Codes map[codes.Code]int
+
+	// LastErr is the error returned by the last
+	// failed iteration.
+	LastErr error
+
+	// TeardownErrors is the number of Teardown calls
+	// that failed and LastTeardownErr is the error of
+	// the last one.
+	TeardownErrors  int
+	LastTeardownErr error
+
+	// Err is non-nil if the run was abandoned
+	// because it exceeded its failure budget or
+	// its context was done, or if it ended without
+	// any successful iteration.
+	Err error
+
+	// Duration is the wall time spent running
+	// all iterations.
+	Duration time.Duration
+
+	// Percentile is the percentile of Elapsed the
+	// confidence interval is computed for, and
+	// Confidence is the confidence level.
+	Percentile float64
+	Confidence float64
+
+	// Estimate is the Percentile of Elapsed and
+	// [CILow, CIHigh] is its confidence interval.
+	Estimate int64
+	CILow    int64
+	CIHigh   int64
+
+	// Stable is true if the confidence interval is
+	// narrower than Config.Stabilize requires.
+	Stable bool
+}
+
+// RelativeWidth returns the width of the confidence
+// interval relative to the estimate.
+func (r *Result) RelativeWidth() float64 {
+	return relativeWidth(r.CILow, r.CIHigh, r.Estimate)
+}
+
+// Throughput returns the number of successful
+// iterations per second.
+func (r *Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(len(r.Elapsed)) / r.Duration.Seconds()
+}
+
+// IntervalString formats the confidence interval, e.g.
+// "p50 = 120ms [115ms, 126ms] (width 9.2%) at 95%, stable".
+func (r *Result) IntervalString() string {
+	stable := "not stable"
+	if r.Stable {
+		stable = "stable"
+	}
+	return fmt.Sprintf("p%v = %v [%v, %v] (width %.1f%%) at %v%%, %v",
+		r.Percentile, time.Duration(r.Estimate), time.Duration(r.CILow), time.Duration(r.CIHigh),
+		100*r.RelativeWidth(), 100*r.Confidence, stable)
+}
+
+// CodesString formats the error counts by status
+// code, e.g. "Aborted: 2, DeadlineExceeded: 1".
+func (r *Result) CodesString() string {
+	return FormatCodes(r.Codes)
+}
+
+// FormatCodes formats error counts by status code
+// in a stable order.
+func FormatCodes(counts map[codes.Code]int) string {
+	keys := make([]codes.Code, 0, len(counts))
+	for c := range counts {
+		keys = append(keys, c)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, c := range keys {
+		parts[i] = fmt.Sprintf("%v: %v", c, counts[c])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Run calls fn until cfg.N iterations succeed (or
+// cfg.Duration passes), the failure budget is spent
+// or ctx is done.
+// Each iteration receives a context derived from ctx
+// that carries the per-iteration timeout, if any.
+// With concurrency, fn is called from multiple
+// goroutines and should be safe for concurrent use.
+func Run(ctx context.Context, cfg Config, fn func(ctx context.Context) error) *Result {
+	workers := cfg.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	maxFailures := cfg.maxFailures()
+
+	var (
+		mu      sync.Mutex
+		claimed int // number of iterations started or done
+		slot    int // next slot in the rate schedule
+	)
+	result := &Result{}
+	start := time.Now()
+	// next reserves the next iteration for a worker and
+	// returns the time it is supposed to start at.
+	next := func() (time.Time, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if result.Err != nil {
+			return time.Time{}, false
+		}
+		if err := ctx.Err(); err != nil {
+			result.Err = err
+			return time.Time{}, false
+		}
+		at := time.Now()
+		if cfg.Rate > 0 {
+			at = start.Add(time.Duration(float64(slot) / cfg.Rate * float64(time.Second)))
+		}
+		if cfg.Duration > 0 {
+			if at.Sub(start) >= cfg.Duration {
+				return time.Time{}, false
+			}
+		} else {
+			if cfg.done(claimed, result.Elapsed) {
+				return time.Time{}, false
+			}
+			claimed++
+		}
+		slot++
+		return at, true
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				iterStart, ok := next()
+				if !ok {
+					return
+				}
+				if !sleepUntil(ctx, iterStart) {
+					mu.Lock()
+					if result.Err == nil {
+						result.Err = ctx.Err()
+					}
+					mu.Unlock()
+					return
+				}
+				measureFrom := iterStart
+				if cfg.Rate <= 0 {
+					measureFrom = time.Time{} // after setup
+				}
+				dur, err, terr := runIteration(ctx, cfg, measureFrom, fn)
+
+				mu.Lock()
+				result.teardownFailed(terr)
+				if err != nil && ctx.Err() != nil {
+					// Run is cancelled, don't count it as a failure.
+					claimed--
+					mu.Unlock()
+					continue
+				}
+				if err != nil {
+					result.fail(err)
+					if maxFailures >= 0 && result.Errors > maxFailures && result.Err == nil {
+						result.Err = fmt.Errorf("failed too many times (%d): %v", result.Errors, err)
+					}
+					claimed-- // give the iteration back to be retried
+					mu.Unlock()
+					continue
+				}
+				result.Elapsed = append(result.Elapsed, int64(dur))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
+	if result.Err == nil && len(result.Elapsed) == 0 && result.Errors > 0 {
+		// Possible in duration mode, where failures
+		// are only bounded by Duration.
+		result.Err = fmt.Errorf("no iteration succeeded (%d failed): %v", result.Errors, result.LastErr)
+	}
+
+	result.Percentile = cfg.percentile()
+	result.Confidence = cfg.confidence()
+	result.Estimate = stats.PercentileInt64(result.Percentile, result.Elapsed...)
+	result.CILow, result.CIHigh = stats.PercentileCI(result.Percentile, result.Confidence, result.Elapsed...)
+	result.Stable = cfg.Stabilize > 0 && result.RelativeWidth() <= cfg.Stabilize
+	return result
+}
+
+// sleepUntil waits until t. It reports false
+// if ctx is done before.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runIteration calls fn between the setup and teardown
+// hooks of cfg and returns how long it took since start
+// without the setup, or since it was called if start is
+// zero. The error of the teardown is returned separately
+// so it doesn't fail an iteration that was measured.
+func runIteration(ctx context.Context, cfg Config, start time.Time, fn func(ctx context.Context) error) (dur time.Duration, err, teardownErr error) {
+	var setup time.Duration
+	if cfg.Setup != nil {
+		setupStart := time.Now()
+		if err := runOne(ctx, cfg.Timeout, cfg.Setup); err != nil {
+			return 0, err, nil
+		}
+		setup = time.Since(setupStart)
+	}
+	if start.IsZero() {
+		start, setup = time.Now(), 0
+	}
+	err = runOne(ctx, cfg.Timeout, fn)
+	dur = time.Since(start) - setup
+	if cfg.Teardown != nil {
+		teardownErr = runOne(ctx, cfg.Timeout, cfg.Teardown)
+	}
+	return dur, err, teardownErr
+}
+
+func runOne(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// teardownFailed counts err if it's not nil.
+func (r *Result) teardownFailed(err error) {
+	if err == nil {
+		return
+	}
+	r.TeardownErrors++
+	r.LastTeardownErr = err
+}
+
+func (r *Result) fail(err error) {
+	if r.Codes == nil {
+		r.Codes = make(map[codes.Code]int)
+	}
+	r.Errors++
+	r.Codes[spanner.ErrCode(err)]++
+	r.LastErr = err
+}
@@ -0,0 +1,430 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// inFlight counts the calls of fn running at the
+// same time and records the maximum.
+type inFlight struct {
+	mu       sync.Mutex
+	calls    int
+	cur, max int
+}
+
+func (f *inFlight) wrap(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		f.mu.Lock()
+		f.calls++
+		f.cur++
+		if f.cur > f.max {
+			f.max = f.cur
+		}
+		f.mu.Unlock()
+		defer func() {
+			f.mu.Lock()
+			f.cur--
+			f.mu.Unlock()
+		}()
+		return fn(ctx)
+	}
+}
+
+func TestRunConcurrency(t *testing.T) {
+	tests := []struct {
+		n, concurrency int
+		wantMax        int
+	}{
+		{n: 10, concurrency: 0, wantMax: 1},
+		{n: 10, concurrency: 1, wantMax: 1},
+		{n: 20, concurrency: 4, wantMax: 4},
+		{n: 3, concurrency: 8, wantMax: 3},
+	}
+	for _, tt := range tests {
+		var f inFlight
+		r := Run(context.Background(), Config{N: tt.n, Concurrency: tt.concurrency}, f.wrap(func(ctx context.Context) error {
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}))
+		if len(r.Elapsed) != tt.n || f.calls != tt.n {
+			t.Errorf("Run(N=%v, Concurrency=%v) collected %v samples in %v calls; want %v",
+				tt.n, tt.concurrency, len(r.Elapsed), f.calls, tt.n)
+		}
+		if f.max != tt.wantMax {
+			t.Errorf("Run(N=%v, Concurrency=%v) ran %v calls at once; want %v",
+				tt.n, tt.concurrency, f.max, tt.wantMax)
+		}
+		for _, e := range r.Elapsed {
+			if e < int64(5*time.Millisecond) {
+				t.Errorf("Run(N=%v, Concurrency=%v) measured %v; want at least 5ms",
+					tt.n, tt.concurrency, time.Duration(e))
+				break
+			}
+		}
+		if r.Duration <= 0 || r.Throughput() <= 0 {
+			t.Errorf("Run(N=%v, Concurrency=%v) took %v (%v/s); want a positive duration",
+				tt.n, tt.concurrency, r.Duration, r.Throughput())
+		}
+	}
+}
+
+// failFirst returns a function that fails its
+// first n calls with err and then succeeds.
+func failFirst(n int, err error) func(ctx context.Context) error {
+	var mu sync.Mutex
+	calls := 0
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls <= n {
+			return err
+		}
+		return nil
+	}
+}
+
+// budget returns a pointer to a MaxFailures of n.
+func budget(n int) *int {
+	return &n
+}
+
+func TestRunFailureBudget(t *testing.T) {
+	aborted := status.Error(codes.Aborted, "aborted")
+	tests := []struct {
+		name        string
+		cfg         Config
+		failures    int
+		wantErrors  int
+		wantSamples int
+		wantErr     bool
+	}{
+		{"no failures", Config{N: 5}, 0, 0, 5, false},
+		{"retried", Config{N: 5}, 3, 3, 5, false},
+		{"default budget", Config{N: 5}, 10, 10, 5, false},
+		{"default budget spent", Config{N: 5}, 11, 11, 0, true},
+		{"budget", Config{N: 5, MaxFailures: budget(2)}, 2, 2, 5, false},
+		{"budget spent", Config{N: 5, MaxFailures: budget(2)}, 3, 3, 0, true},
+		{"no failures tolerated", Config{N: 5, MaxFailures: budget(0)}, 0, 0, 5, false},
+		{"no failures tolerated spent", Config{N: 5, MaxFailures: budget(0)}, 1, 1, 0, true},
+		{"unlimited", Config{N: 5, MaxFailures: budget(-1)}, 50, 50, 5, false},
+		{"concurrent", Config{N: 20, Concurrency: 4}, 7, 7, 20, false},
+	}
+	for _, tt := range tests {
+		r := Run(context.Background(), tt.cfg, failFirst(tt.failures, aborted))
+		if r.Errors != tt.wantErrors || len(r.Elapsed) != tt.wantSamples || (r.Err != nil) != tt.wantErr {
+			t.Errorf("%v: Run() = %v errors, %v samples, Err %v; want %v errors, %v samples, Err %v",
+				tt.name, r.Errors, len(r.Elapsed), r.Err, tt.wantErrors, tt.wantSamples, tt.wantErr)
+		}
+		if r.Codes[codes.Aborted] != r.Errors {
+			t.Errorf("%v: Run() counted %v; want %v Aborted", tt.name, r.Codes, r.Errors)
+		}
+		if tt.failures > 0 && r.LastErr != aborted {
+			t.Errorf("%v: Run() LastErr = %v; want %v", tt.name, r.LastErr, aborted)
+		}
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	r := Run(ctx, Config{N: 10}, func(ctx context.Context) error {
+		calls++
+		if calls == 3 {
+			cancel()
+			return ctx.Err()
+		}
+		return nil
+	})
+	if r.Err != context.Canceled {
+		t.Errorf("Run() Err = %v; want %v", r.Err, context.Canceled)
+	}
+	if len(r.Elapsed) != 2 || r.Errors != 0 || calls != 3 {
+		t.Errorf("Run() = %v samples, %v errors in %v calls; want 2 samples, no errors in 3 calls",
+			len(r.Elapsed), r.Errors, calls)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	r := Run(ctx, Config{N: 10}, func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+	if r.Err != context.Canceled || calls != 0 {
+		t.Errorf("Run() Err = %v after %v calls; want %v before any call", r.Err, calls, context.Canceled)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	r := Run(context.Background(), Config{N: 3, Timeout: 10 * time.Millisecond, MaxFailures: budget(1)},
+		func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("iteration context has no deadline")
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	if r.Err == nil || r.Errors != 2 || r.LastErr != context.DeadlineExceeded {
+		t.Errorf("Run() = %v errors, LastErr %v, Err %v; want 2 deadline errors and Err set",
+			r.Errors, r.LastErr, r.Err)
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	tests := []struct {
+		concurrency int
+	}{
+		{1},
+		{4},
+	}
+	for _, tt := range tests {
+		const d = 50 * time.Millisecond
+		r := Run(context.Background(), Config{N: 1, Duration: d, Concurrency: tt.concurrency},
+			func(ctx context.Context) error {
+				time.Sleep(time.Millisecond)
+				return nil
+			})
+		if r.Err != nil || r.Duration < d || r.Duration > d+time.Second {
+			t.Errorf("Run(Concurrency=%v) ran for %v with Err %v; want about %v",
+				tt.concurrency, r.Duration, r.Err, d)
+		}
+		if len(r.Elapsed) < 2 {
+			t.Errorf("Run(Concurrency=%v) collected %v samples; want more than N", tt.concurrency, len(r.Elapsed))
+		}
+	}
+}
+
+func TestRunDurationFailing(t *testing.T) {
+	aborted := status.Error(codes.Aborted, "aborted")
+	r := Run(context.Background(), Config{N: 1, Duration: 30 * time.Millisecond}, func(ctx context.Context) error {
+		time.Sleep(time.Millisecond)
+		return aborted
+	})
+	if r.Err == nil {
+		t.Errorf("Run() = nil; want an error if no iteration succeeded")
+	}
+	if r.Errors < 2 || len(r.Elapsed) != 0 || r.LastErr != aborted {
+		t.Errorf("Run() had %v errors and %v samples, last error %v; want failures only", r.Errors, len(r.Elapsed), r.LastErr)
+	}
+}
+
+func TestRunRate(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	tests := []struct {
+		name        string
+		concurrency int
+		work        time.Duration
+		minMax      time.Duration // lower bound of the largest latency
+		maxMax      time.Duration // upper bound of the largest latency
+	}{
+		// Enough workers to keep up: latencies are the work.
+		{"keeps up", 10, 20 * time.Millisecond, 20 * time.Millisecond, 60 * time.Millisecond},
+		// A single worker falls behind the schedule and the
+		// queueing delay is measured from the intended start:
+		// the 10th run starts 90ms late.
+		{"falls behind", 1, 20 * time.Millisecond, 100 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		var starts []time.Duration
+		begin := time.Now()
+		r := Run(context.Background(), Config{N: 10, Rate: float64(time.Second / interval), Concurrency: tt.concurrency},
+			func(ctx context.Context) error {
+				mu.Lock()
+				starts = append(starts, time.Since(begin))
+				mu.Unlock()
+				time.Sleep(tt.work)
+				return nil
+			})
+		if len(r.Elapsed) != 10 {
+			t.Fatalf("%v: Run() collected %v samples; want 10", tt.name, len(r.Elapsed))
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+		for i, s := range starts {
+			if s < time.Duration(i)*interval {
+				t.Errorf("%v: run %v started at %v; want it not before %v", tt.name, i, s, time.Duration(i)*interval)
+			}
+		}
+		var max int64
+		for _, e := range r.Elapsed {
+			if e > max {
+				max = e
+			}
+		}
+		if got := time.Duration(max); got < tt.minMax || got > tt.maxMax {
+			t.Errorf("%v: Run() max latency = %v; want in [%v, %v]", tt.name, got, tt.minMax, tt.maxMax)
+		}
+	}
+}
+
+func TestRunRateDuration(t *testing.T) {
+	r := Run(context.Background(), Config{Rate: 100, Duration: 100 * time.Millisecond, Concurrency: 4},
+		func(ctx context.Context) error { return nil })
+	// Slots at 0, 10, ..., 90ms fit in the duration.
+	if len(r.Elapsed) != 10 {
+		t.Errorf("Run() collected %v samples; want 10", len(r.Elapsed))
+	}
+}
+
+func TestRunStabilize(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        Config
+		minSamples int
+		maxSamples int
+		wantStable bool
+	}{
+		// Runs of about the same latency stabilize quickly.
+		{"stable", Config{N: 10, Stabilize: 0.5, MaxN: 200}, 10, 199, true},
+		// An impossible width keeps running until MaxN.
+		{"max n", Config{N: 10, Stabilize: 1e-9, MaxN: 30}, 30, 30, false},
+		{"default max n", Config{N: 5, Stabilize: 1e-9}, 50, 50, false},
+		{"concurrent", Config{N: 10, Stabilize: 1e-9, MaxN: 30, Concurrency: 4}, 30, 30, false},
+	}
+	for _, tt := range tests {
+		r := Run(context.Background(), tt.cfg, func(ctx context.Context) error {
+			time.Sleep(2 * time.Millisecond)
+			return nil
+		})
+		if n := len(r.Elapsed); n < tt.minSamples || n > tt.maxSamples {
+			t.Errorf("%v: Run() collected %v samples; want [%v, %v]", tt.name, n, tt.minSamples, tt.maxSamples)
+		}
+		if r.Stable != tt.wantStable {
+			t.Errorf("%v: Run() Stable = %v; want %v", tt.name, r.Stable, tt.wantStable)
+		}
+		if r.Percentile != 50 || r.Confidence != 0.95 {
+			t.Errorf("%v: Run() interval is p%v at %v; want p50 at 0.95", tt.name, r.Percentile, r.Confidence)
+		}
+		if r.CILow > r.Estimate || r.Estimate > r.CIHigh {
+			t.Errorf("%v: Run() estimate %v is outside of [%v, %v]", tt.name, r.Estimate, r.CILow, r.CIHigh)
+		}
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	aborted := status.Error(codes.Aborted, "aborted")
+	tests := []struct {
+		name         string
+		setupFails   int
+		fnFails      int
+		teardownFail int
+		wantErrors   int
+		wantSetups   int
+		wantCalls    int
+		wantTeardown int
+		wantTDErrors int
+	}{
+		{name: "no failures", wantSetups: 5, wantCalls: 5, wantTeardown: 5},
+		// A failed setup fails the iteration without calling fn.
+		{name: "setup fails", setupFails: 2, wantErrors: 2, wantSetups: 7, wantCalls: 5, wantTeardown: 5},
+		// Teardown is called even if the iteration fails.
+		{name: "fn fails", fnFails: 2, wantErrors: 2, wantSetups: 7, wantCalls: 7, wantTeardown: 7},
+		// A failed teardown doesn't fail the iteration it follows.
+		{name: "teardown fails", teardownFail: 2, wantSetups: 5, wantCalls: 5, wantTeardown: 5, wantTDErrors: 2},
+	}
+	for _, tt := range tests {
+		var setups, calls, teardowns int
+		cfg := Config{
+			N: 5,
+			Setup: func(ctx context.Context) error {
+				setups++
+				if setups <= tt.setupFails {
+					return aborted
+				}
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			},
+			Teardown: func(ctx context.Context) error {
+				teardowns++
+				if teardowns <= tt.teardownFail {
+					return aborted
+				}
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			},
+		}
+		r := Run(context.Background(), cfg, func(ctx context.Context) error {
+			calls++
+			if calls <= tt.fnFails {
+				return aborted
+			}
+			return nil
+		})
+		if r.Err != nil {
+			t.Errorf("%v: Run() = %v; want no error", tt.name, r.Err)
+		}
+		if r.Errors != tt.wantErrors {
+			t.Errorf("%v: Run() had %v errors; want %v", tt.name, r.Errors, tt.wantErrors)
+		}
+		if r.TeardownErrors != tt.wantTDErrors {
+			t.Errorf("%v: Run() had %v teardown errors; want %v", tt.name, r.TeardownErrors, tt.wantTDErrors)
+		}
+		if tt.wantTDErrors > 0 && r.LastTeardownErr != aborted {
+			t.Errorf("%v: Run() LastTeardownErr = %v; want %v", tt.name, r.LastTeardownErr, aborted)
+		}
+		if setups != tt.wantSetups || calls != tt.wantCalls || teardowns != tt.wantTeardown {
+			t.Errorf("%v: Run() called setup, fn and teardown %v, %v and %v times; want %v, %v and %v",
+				tt.name, setups, calls, teardowns, tt.wantSetups, tt.wantCalls, tt.wantTeardown)
+		}
+		if len(r.Elapsed) != 5 {
+			t.Errorf("%v: Run() collected %v samples; want 5", tt.name, len(r.Elapsed))
+		}
+		// The hooks are outside of the timed region.
+		for _, d := range r.Elapsed {
+			if time.Duration(d) >= 20*time.Millisecond {
+				t.Errorf("%v: Run() latency = %v; want less than the hooks", tt.name, time.Duration(d))
+				break
+			}
+		}
+	}
+}
+
+func TestRunHooksRate(t *testing.T) {
+	cfg := Config{
+		N:    3,
+		Rate: 10,
+		Setup: func(ctx context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		},
+	}
+	r := Run(context.Background(), cfg, func(ctx context.Context) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if len(r.Elapsed) != 3 {
+		t.Fatalf("Run() collected %v samples; want 3", len(r.Elapsed))
+	}
+	// Latencies are measured from the scheduled start,
+	// but without the setup.
+	for _, d := range r.Elapsed {
+		if d := time.Duration(d); d < time.Millisecond || d >= 20*time.Millisecond {
+			t.Errorf("Run() latency = %v; want [1ms, 20ms)", d)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// WarmUp runs untimed iterations before a benchmark so
+// sessions and connections are ready once it is measured.
+// It runs cfg.WarmUp iterations, or keeps running iterations
+// for cfg.WarmUpDuration if set. Failed iterations are
+// counted but never abandon the warm-up. WarmUp returns
+// nil if no warm-up is configured.
+func WarmUp(ctx context.Context, cfg Config, fn func(ctx context.Context) error) *Result {
+	if cfg.WarmUp <= 0 && cfg.WarmUpDuration <= 0 {
+		return nil
+	}
+	workers := cfg.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		mu      sync.Mutex
+		claimed int
+	)
+	start := time.Now()
+	end := start.Add(cfg.WarmUpDuration)
+	next := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() != nil {
+			return false
+		}
+		if cfg.WarmUpDuration > 0 {
+			return time.Now().Before(end)
+		}
+		if claimed >= cfg.WarmUp {
+			return false
+		}
+		claimed++
+		return true
+	}
+
+	result := &Result{}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next() {
+				dur, err, terr := runIteration(ctx, cfg, time.Time{}, fn)
+
+				mu.Lock()
+				result.teardownFailed(terr)
+				if err != nil {
+					result.fail(err)
+				} else {
+					result.Elapsed = append(result.Elapsed, int64(dur))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
+	return result
+}
+
+// PrefillSessions makes sure the client's session pool has
+// at least n sessions by holding n read-only transactions
+// open at the same time. Each transaction holds its own
+// session until all of them have run a query, so the pool
+// has to create the missing ones. The pool may later expire
+// idle sessions above its MinOpened.
+func PrefillSessions(ctx context.Context, client *spanner.Client, n int) error {
+	txs := make([]*spanner.ReadOnlyTransaction, n)
+	for i := range txs {
+		txs[i] = client.ReadOnlyTransaction()
+	}
+	defer func() {
+		for _, tx := range txs {
+			tx.Close()
+		}
+	}()
+
+	errs := make(chan error, n)
+	for _, tx := range txs {
+		go func(tx *spanner.ReadOnlyTransaction) {
+			it := tx.Query(ctx, spanner.NewStatement("SELECT 1"))
+			defer it.Stop()
+			errs <- it.Do(func(r *spanner.Row) error {
+				return nil
+			})
+		}(tx)
+	}
+	var firstErr error
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
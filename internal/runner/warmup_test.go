@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWarmUp(t *testing.T) {
+	failing := errors.New("warm-up failed")
+	tests := []struct {
+		name        string
+		cfg         Config
+		err         error
+		wantNil     bool
+		wantSamples int
+		wantErrors  int
+	}{
+		{name: "none", cfg: Config{N: 5}, wantNil: true},
+		{name: "iterations", cfg: Config{N: 5, WarmUp: 3}, wantSamples: 3},
+		{name: "concurrent", cfg: Config{N: 5, WarmUp: 8, Concurrency: 4}, wantSamples: 8},
+		{name: "failures", cfg: Config{N: 5, WarmUp: 3}, err: failing, wantErrors: 3},
+	}
+	for _, tt := range tests {
+		r := WarmUp(context.Background(), tt.cfg, func(ctx context.Context) error {
+			return tt.err
+		})
+		if tt.wantNil {
+			if r != nil {
+				t.Errorf("%v: WarmUp() = %+v; want nil", tt.name, r)
+			}
+			continue
+		}
+		if r == nil {
+			t.Errorf("%v: WarmUp() = nil", tt.name)
+			continue
+		}
+		if len(r.Elapsed) != tt.wantSamples || r.Errors != tt.wantErrors || r.Err != nil {
+			t.Errorf("%v: WarmUp() = %v samples, %v errors, Err %v; want %v samples, %v errors",
+				tt.name, len(r.Elapsed), r.Errors, r.Err, tt.wantSamples, tt.wantErrors)
+		}
+	}
+}
+
+func TestWarmUpDuration(t *testing.T) {
+	r := WarmUp(context.Background(), Config{WarmUp: 1, WarmUpDuration: 30 * time.Millisecond},
+		func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			return nil
+		})
+	if r.Duration < 30*time.Millisecond || len(r.Elapsed) < 2 {
+		t.Errorf("WarmUp() ran %v iterations in %v; want iterations for at least 30ms", len(r.Elapsed), r.Duration)
+	}
+}
+
+func TestWarmUpSeparate(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	fn := func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return nil
+	}
+	cfg := Config{N: 5, WarmUp: 3, Concurrency: 2}
+	w := WarmUp(context.Background(), cfg, fn)
+	r := Run(context.Background(), cfg, fn)
+	if len(w.Elapsed) != 3 || len(r.Elapsed) != 5 || calls != 8 {
+		t.Errorf("WarmUp() and Run() collected %v and %v samples in %v calls; want 3 and 5 in 8 calls",
+			len(w.Elapsed), len(r.Elapsed), calls)
+	}
+}
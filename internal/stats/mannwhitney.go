@@ -0,0 +1,81 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// MannWhitneyU runs a two-sided Mann-Whitney U test on
+// samples x and y, and returns the U statistic of x and
+// the p-value. A small p-value means x and y are unlikely
+// to come from the same distribution. The p-value uses the
+// normal approximation with tie correction, so it is only
+// accurate with more than a handful of samples. It returns
+// a p-value of 1 if either sample is empty.
+func MannWhitneyU(x, y []int64) (u, p float64) {
+	n1, n2 := len(x), len(y)
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+
+	type sample struct {
+		v     int64
+		fromX bool
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].v < all[j].v
+	})
+
+	// Rank samples, averaging the ranks of ties.
+	var rankSumX, tieTerm float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // average of ranks i+1..j
+		for k := i; k < j; k++ {
+			if all[k].fromX {
+				rankSumX += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+
+	fn1, fn2 := float64(n1), float64(n2)
+	n := fn1 + fn2
+	u = rankSumX - fn1*(fn1+1)/2
+	mean := fn1 * fn2 / 2
+	variance := fn1 * fn2 / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return u, 1 // all samples are equal
+	}
+	z := (math.Abs(u-mean) - 0.5) / math.Sqrt(variance) // continuity correction
+	if z < 0 {
+		z = 0
+	}
+	return u, math.Erfc(z / math.Sqrt2)
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMannWhitneyU(t *testing.T) {
+	tests := []struct {
+		name  string
+		x, y  []int64
+		wantU float64
+		wantP float64
+	}{
+		{"empty x", nil, []int64{1, 2}, 0, 1},
+		{"empty y", []int64{1, 2}, nil, 0, 1},
+		{"all equal", []int64{5, 5, 5}, []int64{5, 5}, 3, 1},
+		{"same samples", seq(10), seq(10), 50, 1},
+		{"separated", seq(10), []int64{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}, 0, 0.000182672},
+		{"ties", []int64{1, 2, 2, 3}, []int64{2, 3, 3, 4}, 3, 0.172034},
+	}
+	for _, tt := range tests {
+		u, p := MannWhitneyU(tt.x, tt.y)
+		if u != tt.wantU || math.Abs(p-tt.wantP) > 1e-6 {
+			t.Errorf("%v: MannWhitneyU(%v, %v) = %v, %v; want %v, %v",
+				tt.name, tt.x, tt.y, u, p, tt.wantU, tt.wantP)
+		}
+	}
+}
+
+func TestMannWhitneyUSymmetric(t *testing.T) {
+	x := []int64{12, 15, 11, 19, 14, 13}
+	y := []int64{18, 21, 17, 16, 22, 20, 15}
+	ux, px := MannWhitneyU(x, y)
+	uy, py := MannWhitneyU(y, x)
+	if ux+uy != float64(len(x)*len(y)) {
+		t.Errorf("U(x, y) + U(y, x) = %v; want %v", ux+uy, len(x)*len(y))
+	}
+	if math.Abs(px-py) > 1e-12 {
+		t.Errorf("p(x, y) = %v, p(y, x) = %v; want them equal", px, py)
+	}
+}
@@ -19,16 +19,55 @@ import (
 	"sort"
 )
 
+// MedianInt64 returns the median of x, which is
+// its 50th percentile as PercentileInt64 defines it.
 func MedianInt64(x ...int64) int64 {
+	return PercentileInt64(50, x...)
+}
+
+// PercentileInt64 returns the pth percentile of x
+// using the nearest-rank method. p is in [0, 100].
+func PercentileInt64(p float64, x ...int64) int64 {
 	count := len(x)
 	if count == 0 {
-		return 0 // TODO(jbd): NaN?
+		return 0
 	}
 	x = SortInt64s(x)
-	if count%2 == 0 {
-		return x[count/2-1]
+	rank := int(math.Ceil(p / 100 * float64(count)))
+	if rank < 1 {
+		rank = 1
 	}
-	return x[count/2]
+	if rank > count {
+		rank = count
+	}
+	return x[rank-1]
+}
+
+// PercentileCI returns a distribution-free confidence
+// interval for the pth percentile of x at the given
+// confidence level, e.g. 0.95. The bounds are order
+// statistics picked with the normal approximation of
+// the binomial distribution, so it needs a reasonable
+// number of samples to be meaningful.
+func PercentileCI(p, confidence float64, x ...int64) (lo, hi int64) {
+	count := len(x)
+	if count == 0 {
+		return 0, 0
+	}
+	x = SortInt64s(x)
+	q := p / 100
+	z := math.Sqrt2 * math.Erfinv(confidence)
+	n := float64(count)
+	spread := z * math.Sqrt(n*q*(1-q))
+	j := int(math.Floor(n*q - spread)) // 1-indexed ranks
+	k := int(math.Ceil(n*q + spread))
+	if j < 1 {
+		j = 1
+	}
+	if k > count {
+		k = count
+	}
+	return x[j-1], x[k-1]
 }
 
 func SortInt64s(x []int64) []int64 {
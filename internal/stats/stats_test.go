@@ -0,0 +1,93 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import "testing"
+
+// seq returns the integers in [1, n].
+func seq(n int) []int64 {
+	x := make([]int64, n)
+	for i := range x {
+		x[i] = int64(i + 1)
+	}
+	return x
+}
+
+func TestPercentileCI(t *testing.T) {
+	tests := []struct {
+		name           string
+		p, confidence  float64
+		x              []int64
+		wantLo, wantHi int64
+	}{
+		{"empty", 50, 0.95, nil, 0, 0},
+		{"single", 50, 0.95, []int64{7}, 7, 7},
+		{"median", 50, 0.95, seq(100), 40, 60},
+		{"lower confidence", 50, 0.5, seq(100), 46, 54},
+		{"unsorted", 50, 0.95, []int64{5, 1, 4, 2, 3}, 1, 5},
+		{"high percentile", 99, 0.95, seq(10), 9, 10},
+	}
+	for _, tt := range tests {
+		lo, hi := PercentileCI(tt.p, tt.confidence, tt.x...)
+		if lo != tt.wantLo || hi != tt.wantHi {
+			t.Errorf("%v: PercentileCI(%v, %v) = [%v, %v]; want [%v, %v]",
+				tt.name, tt.p, tt.confidence, lo, hi, tt.wantLo, tt.wantHi)
+		}
+	}
+}
+
+func TestPercentileInt64(t *testing.T) {
+	tests := []struct {
+		p    float64
+		x    []int64
+		want int64
+	}{
+		{50, nil, 0},
+		{50, []int64{7}, 7},
+		{99, []int64{7}, 7},
+		{0, seq(10), 1},
+		{50, seq(10), 5},
+		{90, seq(10), 9},
+		{99, seq(10), 10},
+		{100, seq(10), 10},
+		{50, []int64{9, 3, 7, 1, 5}, 5},
+		{95, seq(100), 95},
+	}
+	for _, tt := range tests {
+		if got := PercentileInt64(tt.p, tt.x...); got != tt.want {
+			t.Errorf("PercentileInt64(%v, %v) = %v; want %v", tt.p, tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestMedianInt64(t *testing.T) {
+	tests := []struct {
+		x    []int64
+		want int64
+	}{
+		{nil, 0},
+		{[]int64{3, 1, 2}, 2},
+		{[]int64{4, 1, 3, 2}, 2},
+		{[]int64{5, 5, 1, 9}, 5},
+	}
+	for _, tt := range tests {
+		if got := MedianInt64(tt.x...); got != tt.want {
+			t.Errorf("MedianInt64(%v) = %v; want %v", tt.x, got, tt.want)
+		}
+		if got := PercentileInt64(50, tt.x...); got != tt.want {
+			t.Errorf("PercentileInt64(50, %v) = %v; want the median %v", tt.x, got, tt.want)
+		}
+	}
+}
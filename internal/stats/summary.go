@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import "math"
+
+// Summary summarizes a set of samples.
+type Summary struct {
+	Count  int     `json:"count"`
+	Min    int64   `json:"min"`
+	Max    int64   `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	P50    int64   `json:"p50"`
+	P90    int64   `json:"p90"`
+	P95    int64   `json:"p95"`
+	P99    int64   `json:"p99"`
+	P999   int64   `json:"p99.9"`
+}
+
+// Summarize computes the summary of x.
+func Summarize(x ...int64) Summary {
+	if len(x) == 0 {
+		return Summary{}
+	}
+	min, max := MinMaxInt64(x...)
+	return Summary{
+		Count:  len(x),
+		Min:    min,
+		Max:    max,
+		Mean:   MeanInt64(x...),
+		StdDev: StdDevInt64(x...),
+		P50:    PercentileInt64(50, x...),
+		P90:    PercentileInt64(90, x...),
+		P95:    PercentileInt64(95, x...),
+		P99:    PercentileInt64(99, x...),
+		P999:   PercentileInt64(99.9, x...),
+	}
+}
+
+// MinMaxInt64 returns the smallest and the largest
+// values in x. It returns zeros if x is empty.
+func MinMaxInt64(x ...int64) (min, max int64) {
+	if len(x) == 0 {
+		return 0, 0
+	}
+	min, max = x[0], x[0]
+	for _, v := range x[1:] {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	return min, max
+}
+
+// MeanInt64 returns the arithmetic mean of x.
+func MeanInt64(x ...int64) float64 {
+	if len(x) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, v := range x {
+		sum += float64(v)
+	}
+	return sum / float64(len(x))
+}
+
+// StdDevInt64 returns the sample standard deviation
+// of x. It returns zero if there are less than two
+// samples.
+func StdDevInt64(x ...int64) float64 {
+	if len(x) < 2 {
+		return 0
+	}
+	mean := MeanInt64(x...)
+	var sum float64
+	for _, v := range x {
+		d := float64(v) - mean
+		sum += d * d
+	}
+	return math.Sqrt(sum / float64(len(x)-1))
+}
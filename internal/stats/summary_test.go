@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name string
+		x    []int64
+		want Summary
+	}{
+		{"empty", nil, Summary{}},
+		{"single", []int64{5}, Summary{
+			Count: 1, Min: 5, Max: 5, Mean: 5, StdDev: 0,
+			P50: 5, P90: 5, P95: 5, P99: 5, P999: 5,
+		}},
+		{"several", []int64{9, 2, 4, 4, 5, 4, 7, 5}, Summary{
+			Count: 8, Min: 2, Max: 9, Mean: 5, StdDev: math.Sqrt(32.0 / 7),
+			P50: 4, P90: 9, P95: 9, P99: 9, P999: 9,
+		}},
+	}
+	for _, tt := range tests {
+		got := Summarize(tt.x...)
+		if math.Abs(got.StdDev-tt.want.StdDev) > 1e-9 {
+			t.Errorf("%v: Summarize().StdDev = %v; want %v", tt.name, got.StdDev, tt.want.StdDev)
+		}
+		got.StdDev = tt.want.StdDev
+		if got != tt.want {
+			t.Errorf("%v: Summarize() = %+v; want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStdDevInt64(t *testing.T) {
+	tests := []struct {
+		x    []int64
+		want float64
+	}{
+		{nil, 0},
+		{[]int64{3}, 0},
+		{[]int64{3, 3, 3}, 0},
+		{[]int64{1, 2, 3, 4}, math.Sqrt(5.0 / 3)},
+		{[]int64{-2, 2}, math.Sqrt(8)},
+	}
+	for _, tt := range tests {
+		if got := StdDevInt64(tt.x...); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("StdDevInt64(%v) = %v; want %v", tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestMeanInt64Empty(t *testing.T) {
+	if got := MeanInt64(); !math.IsNaN(got) {
+		t.Errorf("MeanInt64() = %v; want NaN", got)
+	}
+}
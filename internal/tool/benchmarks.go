@@ -19,101 +19,221 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
+	"sync"
 
 	"cloud.google.com/go/spanner"
-	"github.com/cloudspannerecosystem/spanner-bench/internal/histogram"
-	"github.com/cloudspannerecosystem/spanner-bench/internal/stats"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/batchread"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/runner"
 	"google.golang.org/api/iterator"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 )
 
 type benchmarks struct {
 	client     *spanner.Client
-	n          int
+	config     runner.Config
+	sessions   int // number of sessions to prefill
+	count      int // number of times to run each benchmark
+	format     string
 	benchmarks []Benchmark
 }
 
-func (b *benchmarks) start() {
+func (b *benchmarks) start(ctx context.Context) []*benchmarkReport {
+	if b.sessions > 0 {
+		if err := runner.PrefillSessions(ctx, b.client, b.sessions); err != nil {
+			log.Printf("Cannot prefill sessions: %v", err)
+		}
+	}
+	count := b.count
+	if count < 1 {
+		count = 1
+	}
+	reports := make([]*benchmarkReport, 0, len(b.benchmarks)*count)
 	for _, bench := range b.benchmarks {
-		b.run(bench)
+		for i := 0; i < count; i++ {
+			if b.format == formatText {
+				printHeader(bench)
+			}
+			report := b.run(ctx, bench)
+			if b.format == formatBench {
+				printBench(report)
+			} else {
+				printText(report)
+			}
+			reports = append(reports, report)
+		}
 	}
+	printFailures(reports)
+	return reports
 }
 
-func (b *benchmarks) run(bench Benchmark) {
-	fmt.Println(bench.Name)
-
-	var fn func() (benchmarkResult, error)
-	if bench.ReadOnly {
+func (b *benchmarks) run(ctx context.Context, bench Benchmark) *benchmarkReport {
+	var fn func(ctx context.Context) (benchmarkResult, error)
+	switch {
+	case bench.Batch:
+		fn = b.makeBatch(bench)
+	case bench.ReadOnly:
 		fn = b.makeReadOnly(bench)
-	} else {
+	case bench.Partitioned:
+		fn = b.makePartitioned(bench)
+	case bench.Mutations != nil && bench.Mutations.Apply:
+		fn = b.makeApply(bench)
+	default:
 		fn = b.makeReadWrite(bench)
 	}
 
-	elapsed, cpu, optimizer := b.runN(fn)
-	fmt.Printf("  %-10v: %v\n", "Latency", time.Duration(stats.MedianInt64(elapsed...)))
-	fmt.Printf("  %-10v: %v\n", "CPU time", time.Duration(stats.MedianInt64(cpu...)))
-	fmt.Printf("  %-10v: %v\n", "Optimizer", time.Duration(stats.MedianInt64(optimizer...)))
-	if histogram := histogram.NewHistogram(elapsed); histogram != nil {
-		fmt.Println("Latency histogram:")
-		fmt.Println(histogram)
+	cfg := b.config
+	if bench.Concurrency > 0 {
+		cfg.Concurrency = bench.Concurrency
+	}
+	cfg.Rate = bench.Rate
+
+	var report *benchmarkReport
+	setup, teardown, err := b.hooks(bench, &cfg)
+	if err == nil && setup != nil {
+		if err = setup(ctx); err != nil {
+			err = fmt.Errorf("setup failed: %v", err)
+		}
+	}
+	if err != nil {
+		report = &benchmarkReport{run: &runner.Result{Err: err}}
+	} else {
+		report = b.runN(ctx, cfg, fn)
+		if teardown != nil {
+			// Clean up even if the run timed out.
+			if err := teardown(context.Background()); err != nil && report.run.Err == nil {
+				report.run.Err = fmt.Errorf("teardown failed: %v", err)
+			}
+		}
+	}
+	report.Name = bench.Name
+	report.bench = bench
+	report.config = cfg
+	return report
+}
+
+// hooks returns the setup and teardown hooks of bench that
+// run once, and sets the ones that run around each run in cfg.
+func (b *benchmarks) hooks(bench Benchmark, cfg *runner.Config) (setup, teardown func(ctx context.Context) error, err error) {
+	for _, h := range []struct {
+		hook *Hook
+		once *func(ctx context.Context) error
+		each *func(ctx context.Context) error
+	}{
+		{bench.Setup, &setup, &cfg.Setup},
+		{bench.Teardown, &teardown, &cfg.Teardown},
+	} {
+		if h.hook == nil {
+			continue
+		}
+		fn, err := h.hook.compile(b.client)
+		if err != nil {
+			return nil, nil, err
+		}
+		if h.hook.Each {
+			*h.each = fn
+		} else {
+			*h.once = fn
+		}
 	}
+	return setup, teardown, nil
 }
 
-func (b *benchmarks) makeReadOnly(bench Benchmark) func() (benchmarkResult, error) {
+func (b *benchmarks) makeReadOnly(bench Benchmark) func(ctx context.Context) (benchmarkResult, error) {
 	stmts := parseSQL(bench.SQL)
-	return func() (benchmarkResult, error) {
-		ctx := context.Background()
+	names := statementParams(stmts, bench.Params)
+	params, err := newParamSet(bench.Params)
+	if err != nil {
+		return failing(err)
+	}
+	keys, err := compileKeys(bench.Read)
+	if err != nil {
+		return failing(err)
+	}
+	return func(ctx context.Context) (benchmarkResult, error) {
 		var result benchmarkResult
+		stmts := bind(stmts, names, params.next())
 
-		tx := b.client.ReadOnlyTransaction()
+		var tx *spanner.ReadOnlyTransaction
+		if bench.singleUse() {
+			tx = b.client.Single()
+		} else {
+			tx = b.client.ReadOnlyTransaction()
+		}
+		if tb := bench.timestampBound(); tb != nil {
+			tx = tx.WithTimestampBound(*tb)
+		}
 		defer tx.Close()
 
-		mode := sppb.ExecuteSqlRequest_PROFILE
 		for _, stmt := range stmts {
-			it := tx.QueryWithOptions(ctx, stmt, spanner.QueryOptions{
-				Mode: &mode,
-				Options: &sppb.ExecuteSqlRequest_QueryOptions{
-					OptimizerVersion: bench.Optimizer,
-				},
-			})
-			r, err := parseBenchmarkResult(it)
+			r, err := query(ctx, tx, stmt, bench.Optimizer)
 			if err != nil {
 				return benchmarkResult{}, err
 			}
-			result.Elapsed += r.Elapsed
-			result.CPUElapsed += r.CPUElapsed
-			result.OptimizerElapsed += r.OptimizerElapsed
-			it.Stop()
+			result.add(r)
+		}
+		if bench.Read != nil {
+			r, err := bench.Read.read(ctx, tx, keys.next())
+			if err != nil {
+				return benchmarkResult{}, err
+			}
+			result.add(r)
 		}
 		return result, nil
 	}
 }
 
-func (b *benchmarks) makeReadWrite(bench Benchmark) func() (benchmarkResult, error) {
-	ctx := context.Background()
-
+func (b *benchmarks) makeReadWrite(bench Benchmark) func(ctx context.Context) (benchmarkResult, error) {
 	stmts := parseSQL(bench.SQL)
-	return func() (benchmarkResult, error) {
+	steps := planSteps(stmts, bench.BatchDML)
+	names := statementParams(stmts, bench.Params)
+	params, err := newParamSet(bench.Params)
+	if err != nil {
+		return failing(err)
+	}
+	keys, err := compileKeys(bench.Read)
+	if err != nil {
+		return failing(err)
+	}
+	mutations := func() []*spanner.Mutation { return nil }
+	if bench.Mutations != nil {
+		if mutations, err = bench.Mutations.compile(); err != nil {
+			return failing(err)
+		}
+	}
+	return func(ctx context.Context) (benchmarkResult, error) {
 		var result benchmarkResult
+		stmts := bind(stmts, names, params.next())
+		ms := mutations()
 
-		mode := sppb.ExecuteSqlRequest_PROFILE
 		_, err := b.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
-			for _, stmt := range stmts {
-				it := tx.QueryWithOptions(ctx, stmt, spanner.QueryOptions{
-					Mode: &mode,
-					Options: &sppb.ExecuteSqlRequest_QueryOptions{
-						OptimizerVersion: bench.Optimizer,
-					},
-				})
-				r, err := parseBenchmarkResult(it)
+			result = benchmarkResult{} // the transaction may be retried
+			for _, s := range steps {
+				var r benchmarkResult
+				var err error
+				switch {
+				case s.batch():
+					r, err = batchUpdate(ctx, tx, stmts[s.start:s.end])
+				case s.dml && !bench.ProfileDML:
+					r, err = update(ctx, tx, stmts[s.start], bench.Optimizer)
+				default:
+					r, err = query(ctx, tx, stmts[s.start], bench.Optimizer)
+				}
 				if err != nil {
 					return err
 				}
-				result.Elapsed += r.Elapsed
-				result.CPUElapsed += r.CPUElapsed
-				result.OptimizerElapsed += r.OptimizerElapsed
-				it.Stop()
+				result.add(r)
+			}
+			if bench.Read != nil {
+				r, err := bench.Read.read(ctx, tx, keys.next())
+				if err != nil {
+					return err
+				}
+				result.add(r)
+			}
+			if len(ms) > 0 {
+				// Mutations are only sent with the commit.
+				result.add(benchmarkResult{RowsModified: int64(len(ms))})
+				return tx.BufferWrite(ms)
 			}
 			return nil
 		})
@@ -121,28 +241,151 @@ func (b *benchmarks) makeReadWrite(bench Benchmark) func() (benchmarkResult, err
 	}
 }
 
-func (b *benchmarks) runN(f func() (benchmarkResult, error)) (elapsed, cpu, optimizer []int64) {
-	var i, retries int
+// makeApply writes mutations blindly with client.Apply.
+func (b *benchmarks) makeApply(bench Benchmark) func(ctx context.Context) (benchmarkResult, error) {
+	mutations, err := bench.Mutations.compile()
+	if err != nil {
+		return failing(err)
+	}
+	return func(ctx context.Context) (benchmarkResult, error) {
+		ms := mutations()
+		if _, err := b.client.Apply(ctx, ms); err != nil {
+			return benchmarkResult{}, err
+		}
+		return benchmarkResult{RowsModified: int64(len(ms))}, nil
+	}
+}
 
-	for {
-		if i == b.n {
-			// TODO(jbd): Stop until result is stabilized.
-			break
+// makeBatch reads the partitions of a query or a read in a
+// batch read-only transaction.
+func (b *benchmarks) makeBatch(bench Benchmark) func(ctx context.Context) (benchmarkResult, error) {
+	stmts := parseSQL(bench.SQL)
+	names := statementParams(stmts, bench.Params)
+	params, err := newParamSet(bench.Params)
+	if err != nil {
+		return failing(err)
+	}
+	keys, err := compileKeys(bench.Read)
+	if err != nil {
+		return failing(err)
+	}
+	tb := spanner.StrongRead()
+	if bound := bench.timestampBound(); bound != nil {
+		tb = *bound
+	}
+	return func(ctx context.Context) (benchmarkResult, error) {
+		var partition batchread.Partitioner
+		if read := bench.Read; read != nil {
+			partition = batchread.Read(read.Table, read.Index, keys.next(), read.Columns)
+		} else {
+			partition = batchread.Query(bind(stmts, names, params.next())[0])
 		}
-		result, err := f()
-		retries++
+		r, err := batchread.Run(ctx, b.client, tb, partition, bench.Parallelism)
 		if err != nil {
-			if retries > 2*b.n {
-				log.Fatalf("Query failed too many times: %v\n", err)
-			}
-			continue
+			return benchmarkResult{}, err
+		}
+		return benchmarkResult{batch: r}, nil
+	}
+}
+
+// makePartitioned runs a statement as Partitioned DML.
+func (b *benchmarks) makePartitioned(bench Benchmark) func(ctx context.Context) (benchmarkResult, error) {
+	stmts := parseSQL(bench.SQL)
+	names := statementParams(stmts, bench.Params)
+	params, err := newParamSet(bench.Params)
+	if err != nil {
+		return failing(err)
+	}
+	return func(ctx context.Context) (benchmarkResult, error) {
+		stmt := bind(stmts, names, params.next())[0]
+		n, err := b.client.PartitionedUpdateWithOptions(ctx, stmt, spanner.QueryOptions{
+			Options: &sppb.ExecuteSqlRequest_QueryOptions{
+				OptimizerVersion: bench.Optimizer,
+			},
+		})
+		if err != nil {
+			return benchmarkResult{}, err
 		}
-		elapsed = append(elapsed, int64(result.Elapsed))
-		cpu = append(cpu, int64(result.CPUElapsed))
-		optimizer = append(optimizer, int64(result.OptimizerElapsed))
-		i++
+		var result benchmarkResult
+		result.add(benchmarkResult{RowsModified: n})
+		return result, nil
+	}
+}
+
+// querier is implemented by read-only and
+// read-write transactions.
+type querier interface {
+	QueryWithOptions(ctx context.Context, stmt spanner.Statement, opts spanner.QueryOptions) *spanner.RowIterator
+}
+
+// query runs stmt in PROFILE mode and returns its stats.
+// With profile_dml, DML is run this way too and the number
+// of modified rows comes from the stats.
+func query(ctx context.Context, tx querier, stmt spanner.Statement, optimizer string) (benchmarkResult, error) {
+	mode := sppb.ExecuteSqlRequest_PROFILE
+	it := tx.QueryWithOptions(ctx, stmt, spanner.QueryOptions{
+		Mode: &mode,
+		Options: &sppb.ExecuteSqlRequest_QueryOptions{
+			OptimizerVersion: optimizer,
+		},
+	})
+	defer it.Stop()
+	return parseBenchmarkResult(it)
+}
+
+// update runs a DML statement with Update, which only
+// returns the number of modified rows.
+func update(ctx context.Context, tx *spanner.ReadWriteTransaction, stmt spanner.Statement, optimizer string) (benchmarkResult, error) {
+	n, err := tx.UpdateWithOptions(ctx, stmt, spanner.QueryOptions{
+		Options: &sppb.ExecuteSqlRequest_QueryOptions{
+			OptimizerVersion: optimizer,
+		},
+	})
+	if err != nil {
+		return benchmarkResult{}, err
+	}
+	return benchmarkResult{RowsModified: n}, nil
+}
+
+// batchUpdate runs DML statements in a single BatchUpdate.
+func batchUpdate(ctx context.Context, tx *spanner.ReadWriteTransaction, stmts []spanner.Statement) (benchmarkResult, error) {
+	counts, err := tx.BatchUpdate(ctx, stmts)
+	if err != nil {
+		return benchmarkResult{}, err
 	}
-	return elapsed, cpu, optimizer
+	var result benchmarkResult
+	for _, n := range counts {
+		result.RowsModified += n
+	}
+	return result, nil
+}
+
+// failing returns a benchmark that always fails with err.
+func failing(err error) func(ctx context.Context) (benchmarkResult, error) {
+	return func(ctx context.Context) (benchmarkResult, error) {
+		return benchmarkResult{}, err
+	}
+}
+
+func (b *benchmarks) runN(ctx context.Context, cfg runner.Config, f func(ctx context.Context) (benchmarkResult, error)) *benchmarkReport {
+	var mu sync.Mutex
+	report := &benchmarkReport{}
+
+	report.warmUp = runner.WarmUp(ctx, cfg, func(ctx context.Context) error {
+		_, err := f(ctx)
+		return err
+	})
+	report.run = runner.Run(ctx, cfg, func(ctx context.Context) error {
+		result, err := f(ctx)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		report.add(result)
+		return nil
+	})
+	return report
 }
 
 func parseSQL(sql string) []spanner.Statement {
@@ -158,6 +401,47 @@ func parseSQL(sql string) []spanner.Statement {
 	return statements
 }
 
+// step is a statement or, with batch DML, a group of
+// consecutive DML statements stmts[start:end] that are
+// run together.
+type step struct {
+	start, end int
+	dml        bool
+}
+
+func (s step) batch() bool {
+	return s.end-s.start > 1
+}
+
+// planSteps splits stmts into steps. If batch is set,
+// consecutive DML statements are grouped into a step.
+func planSteps(stmts []spanner.Statement, batch bool) []step {
+	var steps []step
+	for i, stmt := range stmts {
+		dml := isDML(stmt.SQL)
+		if n := len(steps); batch && dml && n > 0 && steps[n-1].dml {
+			steps[n-1].end = i + 1
+			continue
+		}
+		steps = append(steps, step{start: i, end: i + 1, dml: dml})
+	}
+	return steps
+}
+
+// isDML reports whether sql is an INSERT,
+// UPDATE or DELETE statement.
+func isDML(sql string) bool {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "INSERT", "UPDATE", "DELETE":
+		return true
+	}
+	return false
+}
+
 func parseBenchmarkResult(it *spanner.RowIterator) (benchmarkResult, error) {
 	for { // Required to be able to read the stats.
 		_, err := it.Next()
@@ -169,7 +453,7 @@ func parseBenchmarkResult(it *spanner.RowIterator) (benchmarkResult, error) {
 		}
 	}
 
-	var result benchmarkResult
+	result := benchmarkResult{RowsModified: it.RowCount, stats: it.QueryStats != nil}
 	for k, v := range it.QueryStats {
 		switch k {
 		case "query_plan_creation_time":
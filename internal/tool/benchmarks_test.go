@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsDML(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT 1", false},
+		{"INSERT INTO Users (UserId) VALUES (1)", true},
+		{"  update Users SET Name = 'a' WHERE TRUE", true},
+		{"\nDELETE FROM Users WHERE TRUE", true},
+		{"WITH u AS (SELECT 1) SELECT * FROM u", false},
+		{"", false},
+		{"   ", false},
+		{"INSERTED", false},
+	}
+	for _, tt := range tests {
+		if got := isDML(tt.sql); got != tt.want {
+			t.Errorf("isDML(%q) = %v; want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestPlanSteps(t *testing.T) {
+	const (
+		q = "SELECT 1"
+		d = "UPDATE Users SET Name = 'a' WHERE TRUE"
+	)
+	tests := []struct {
+		name  string
+		sql   []string
+		batch bool
+		want  []step
+	}{
+		{"empty", nil, true, nil},
+		{"queries", []string{q, q}, true, []step{{0, 1, false}, {1, 2, false}}},
+		{"dml", []string{d, d}, false, []step{{0, 1, true}, {1, 2, true}}},
+		{"batch dml", []string{d, d, d}, true, []step{{0, 3, true}}},
+		{"batch around a query", []string{d, d, q, d}, true, []step{{0, 2, true}, {2, 3, false}, {3, 4, true}}},
+		{"query first", []string{q, d, d}, true, []step{{0, 1, false}, {1, 3, true}}},
+	}
+	for _, tt := range tests {
+		got := planSteps(parseSQL(strings.Join(tt.sql, ";")), tt.batch)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: planSteps() = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudspannerecosystem/spanner-bench/internal/stats"
+)
+
+// compareMain implements the compare subcommand.
+func compareMain(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	alpha := fs.Float64("alpha", 0.05, "")
+	fs.Usage = func() {
+		fmt.Println(compareUsageText)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	before, err := readResults(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Cannot read %v: %v", fs.Arg(0), err)
+	}
+	after, err := readResults(fs.Arg(1))
+	if err != nil {
+		log.Fatalf("Cannot read %v: %v", fs.Arg(1), err)
+	}
+	printComparisons(os.Stdout, compare(before, after), *alpha)
+}
+
+func readResults(path string) (*resultsFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f resultsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// samples are the samples of a benchmark
+// pooled from all its runs.
+type samples struct {
+	Elapsed   []int64
+	CPU       []int64
+	Optimizer []int64
+	Client    []int64
+}
+
+// groupByName pools the samples of benchmark runs
+// by name and returns the names in order.
+func groupByName(f *resultsFile) (map[string]*samples, []string) {
+	groups := make(map[string]*samples)
+	var names []string
+	for _, b := range f.Benchmarks {
+		s, ok := groups[b.Name]
+		if !ok {
+			s = &samples{}
+			groups[b.Name] = s
+			names = append(names, b.Name)
+		}
+		s.Elapsed = append(s.Elapsed, b.Elapsed...)
+		s.CPU = append(s.CPU, b.CPU...)
+		s.Optimizer = append(s.Optimizer, b.Optimizer...)
+		s.Client = append(s.Client, b.Client...)
+	}
+	return groups, names
+}
+
+// comparison compares a metric of a benchmark
+// between an old and a new run.
+type comparison struct {
+	Name   string
+	Metric string
+	Old    int64 // median
+	New    int64 // median
+	P      float64
+}
+
+// Delta returns the relative change of the medians.
+func (c comparison) Delta() float64 {
+	if c.Old == 0 {
+		return 0
+	}
+	return float64(c.New-c.Old) / float64(c.Old)
+}
+
+// compare matches benchmarks by name and compares their
+// metrics. Benchmarks missing from either file are skipped.
+func compare(before, after *resultsFile) []comparison {
+	oldGroups, names := groupByName(before)
+	newGroups, _ := groupByName(after)
+
+	var cs []comparison
+	for _, name := range names {
+		o, n := oldGroups[name], newGroups[name]
+		if n == nil {
+			continue
+		}
+		for _, m := range []struct {
+			metric   string
+			old, new []int64
+		}{
+			{"latency", o.Elapsed, n.Elapsed},
+			{"cpu", o.CPU, n.CPU},
+			{"optimizer", o.Optimizer, n.Optimizer},
+			{"client", o.Client, n.Client},
+		} {
+			if len(m.old) == 0 || len(m.new) == 0 {
+				continue
+			}
+			_, p := stats.MannWhitneyU(m.old, m.new)
+			cs = append(cs, comparison{
+				Name:   name,
+				Metric: m.metric,
+				Old:    stats.MedianInt64(m.old...),
+				New:    stats.MedianInt64(m.new...),
+				P:      p,
+			})
+		}
+	}
+	return cs
+}
+
+// printComparisons prints a row per metric. Like benchstat,
+// deltas that are not significant at alpha are shown as "~".
+func printComparisons(w io.Writer, cs []comparison, alpha float64) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\tmetric\told median\tnew median\tdelta\t")
+	for _, c := range cs {
+		delta := "~"
+		if c.P < alpha {
+			delta = fmt.Sprintf("%+.2f%%", 100*c.Delta())
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t(p=%.3f)\n",
+			c.Name, c.Metric, time.Duration(c.Old), time.Duration(c.New), delta, c.P)
+	}
+	tw.Flush()
+}
+
+const compareUsageText = `spannerbench compare [options...] old.json new.json
+
+Compares the results files written with -o. Benchmarks are
+matched by name and the medians of their latency, CPU time,
+optimizer time and client-side latency are compared with a
+Mann-Whitney U test.
+
+Options:
+-alpha   Significance level, by default 0.05. Deltas that are
+         not significant are shown as "~".`
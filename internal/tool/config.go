@@ -14,15 +14,325 @@
 
 package main
 
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
 type Config struct {
-	Database   string      `yaml:"database"`
-	Benchmarks []Benchmark `yaml:"benchmarks"`
+	Database   string      `yaml:"database" json:"database"`
+	Benchmarks []Benchmark `yaml:"benchmarks" json:"benchmarks"`
+
+	// Disposable marks the database as safe to modify
+	// destructively, which partitioned benchmarks require.
+	Disposable bool `yaml:"disposable" json:"disposable,omitempty"`
 }
 
 type Benchmark struct {
-	Name      string `yaml:"name"`
-	SQL       string `yaml:"sql"`
-	Optimizer string `yaml:"optimizer"` // optimizer version
-	ReadOnly  bool   `yaml:"readonly"`
-	// TODO(jbd): Add staleness options.
+	Name      string `yaml:"name" json:"name"`
+	SQL       string `yaml:"sql" json:"sql"`
+	Optimizer string `yaml:"optimizer" json:"optimizer,omitempty"` // optimizer version
+	ReadOnly  bool   `yaml:"readonly" json:"readonly"`
+	SingleUse bool   `yaml:"single_use" json:"single_use,omitempty"` // read in a single-use transaction
+	BatchDML  bool   `yaml:"batch_dml" json:"batch_dml,omitempty"`   // run consecutive DML with BatchUpdate
+
+	// ProfileDML runs DML in PROFILE mode with a query
+	// instead of with Update to report its query stats.
+	ProfileDML bool `yaml:"profile_dml" json:"profile_dml,omitempty"`
+
+	// Setup and Teardown run outside of the timed region
+	// before and after the benchmark, or each run.
+	Setup    *Hook `yaml:"setup" json:"setup,omitempty"`
+	Teardown *Hook `yaml:"teardown" json:"teardown,omitempty"`
+
+	// Tags select benchmarks to run with -tags.
+	Tags []string `yaml:"tags" json:"tags,omitempty"`
+
+	// Batch runs a single query of a read-only benchmark
+	// in a batch read-only transaction and reads its
+	// partitions, Parallelism at a time (by default 1).
+	Batch       bool `yaml:"batch" json:"batch,omitempty"`
+	Parallelism int  `yaml:"parallelism" json:"parallelism,omitempty"`
+
+	// Partitioned runs a single DML statement as
+	// Partitioned DML. It requires a disposable database.
+	Partitioned bool `yaml:"partitioned" json:"partitioned,omitempty"`
+
+	// Timestamp bound of read-only benchmarks. At most one
+	// can be set. Reads are strong if none is set. Bounded
+	// staleness (max_staleness and min_read_timestamp) is
+	// only supported for benchmarks with a single query.
+	Strong           bool       `yaml:"strong" json:"strong,omitempty"`
+	ExactStaleness   Duration   `yaml:"exact_staleness" json:"exact_staleness,omitempty"`
+	MaxStaleness     Duration   `yaml:"max_staleness" json:"max_staleness,omitempty"`
+	ReadTimestamp    *time.Time `yaml:"read_timestamp" json:"read_timestamp,omitempty"`
+	MinReadTimestamp *time.Time `yaml:"min_read_timestamp" json:"min_read_timestamp,omitempty"`
+
+	// Params are the query parameters, referred to as
+	// @name in SQL. New values are generated for each run.
+	Params map[string]Value `yaml:"params" json:"params,omitempty"`
+
+	// Read reads keys with the Read API instead of
+	// running SQL. It can't be combined with SQL.
+	Read *Read `yaml:"read" json:"read,omitempty"`
+
+	// Mutations are written in each run of read-write
+	// benchmarks, after SQL if there is any.
+	Mutations *Mutations `yaml:"mutations" json:"mutations,omitempty"`
+
+	Concurrency int     `yaml:"concurrency" json:"concurrency,omitempty"` // overrides -c
+	Rate        float64 `yaml:"rate" json:"rate,omitempty"`               // target runs per second
+
+	Thresholds *Thresholds `yaml:"thresholds" json:"thresholds,omitempty"`
+}
+
+// validate reports the first benchmark that
+// can't be run as configured.
+func (c *Config) validate() error {
+	for _, b := range c.Benchmarks {
+		if _, err := newParamSet(b.Params); err != nil {
+			return fmt.Errorf("benchmark %q: %v", b.Name, err)
+		}
+		if err := b.validateTimestampBound(); err != nil {
+			return fmt.Errorf("benchmark %q: %v", b.Name, err)
+		}
+		if b.Setup != nil {
+			if err := b.Setup.validate(); err != nil {
+				return fmt.Errorf("benchmark %q: setup: %v", b.Name, err)
+			}
+		}
+		if b.Teardown != nil {
+			if err := b.Teardown.validate(); err != nil {
+				return fmt.Errorf("benchmark %q: teardown: %v", b.Name, err)
+			}
+		}
+		if err := b.validateRead(); err != nil {
+			return fmt.Errorf("benchmark %q: %v", b.Name, err)
+		}
+		if err := b.validateBatch(); err != nil {
+			return fmt.Errorf("benchmark %q: %v", b.Name, err)
+		}
+		if err := b.validatePartitioned(c.Disposable); err != nil {
+			return fmt.Errorf("benchmark %q: %v", b.Name, err)
+		}
+		if err := b.validateMutations(); err != nil {
+			return fmt.Errorf("benchmark %q: %v", b.Name, err)
+		}
+		if b.SingleUse && (!b.ReadOnly || b.reads() > 1) {
+			return fmt.Errorf("benchmark %q: single_use requires a read-only benchmark with a single query or read", b.Name)
+		}
+		if b.ReadOnly {
+			for _, stmt := range parseSQL(b.SQL) {
+				if isDML(stmt.SQL) {
+					return fmt.Errorf("benchmark %q: DML requires a read-write benchmark", b.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (b Benchmark) validateRead() error {
+	if b.Read == nil {
+		return nil
+	}
+	if len(parseSQL(b.SQL)) > 0 || b.Mutations != nil || b.Partitioned {
+		return errors.New("read can't be combined with SQL, mutations or partitioned")
+	}
+	return b.Read.validate()
+}
+
+// reads returns the number of queries and
+// reads the benchmark runs.
+func (b Benchmark) reads() int {
+	n := len(parseSQL(b.SQL))
+	if b.Read != nil {
+		n++
+	}
+	return n
+}
+
+func (b Benchmark) validateBatch() error {
+	if !b.Batch {
+		return nil
+	}
+	if !b.ReadOnly || b.reads() != 1 {
+		return errors.New("batch requires a read-only benchmark with a single query or read")
+	}
+	if b.Read != nil && b.Read.Limit > 0 {
+		return errors.New("batch reads can't be limited")
+	}
+	if b.singleUse() {
+		return errors.New("batch can't be combined with single_use or bounded staleness")
+	}
+	return nil
+}
+
+func (b Benchmark) validatePartitioned(disposable bool) error {
+	if !b.Partitioned {
+		return nil
+	}
+	if !disposable {
+		return errors.New("partitioned DML requires a database marked disposable")
+	}
+	stmts := parseSQL(b.SQL)
+	if b.ReadOnly || b.Mutations != nil || len(stmts) != 1 || !isDML(stmts[0].SQL) {
+		return errors.New("partitioned requires a read-write benchmark with a single DML statement")
+	}
+	return nil
+}
+
+func (b Benchmark) validateMutations() error {
+	if b.Mutations == nil {
+		return nil
+	}
+	if b.ReadOnly {
+		return errors.New("mutations require a read-write benchmark")
+	}
+	if b.Mutations.Apply && len(parseSQL(b.SQL)) > 0 {
+		return errors.New("mutations written with apply can't be combined with SQL")
+	}
+	_, err := b.Mutations.compile()
+	return err
+}
+
+// timestampBound returns the timestamp bound of the
+// benchmark, or nil if it isn't set.
+func (b Benchmark) timestampBound() *spanner.TimestampBound {
+	var tb spanner.TimestampBound
+	switch {
+	case b.Strong:
+		tb = spanner.StrongRead()
+	case b.ExactStaleness > 0:
+		tb = spanner.ExactStaleness(time.Duration(b.ExactStaleness))
+	case b.MaxStaleness > 0:
+		tb = spanner.MaxStaleness(time.Duration(b.MaxStaleness))
+	case b.ReadTimestamp != nil:
+		tb = spanner.ReadTimestamp(*b.ReadTimestamp)
+	case b.MinReadTimestamp != nil:
+		tb = spanner.MinReadTimestamp(*b.MinReadTimestamp)
+	default:
+		return nil
+	}
+	return &tb
+}
+
+// boundedStaleness reports whether the benchmark reads
+// with a bounded staleness, which Spanner only supports
+// in single-use transactions.
+func (b Benchmark) boundedStaleness() bool {
+	return b.MaxStaleness > 0 || b.MinReadTimestamp != nil
+}
+
+// singleUse reports whether the benchmark reads in
+// single-use transactions.
+func (b Benchmark) singleUse() bool {
+	return b.SingleUse || b.boundedStaleness()
+}
+
+func (b Benchmark) validateTimestampBound() error {
+	var n int
+	for _, set := range []bool{
+		b.Strong,
+		b.ExactStaleness > 0,
+		b.MaxStaleness > 0,
+		b.ReadTimestamp != nil,
+		b.MinReadTimestamp != nil,
+	} {
+		if set {
+			n++
+		}
+	}
+	switch {
+	case n == 0:
+		return nil
+	case n > 1:
+		return errors.New("only one timestamp bound can be set")
+	case !b.ReadOnly:
+		return errors.New("timestamp bounds only apply to read-only benchmarks")
+	case b.boundedStaleness() && b.reads() > 1:
+		return errors.New("bounded staleness requires a single query or read")
+	}
+	return nil
+}
+
+// selectBenchmarks returns the benchmarks whose name
+// matches pattern and that have any of the tags. Empty
+// pattern and tags match all benchmarks.
+func selectBenchmarks(benchmarks []Benchmark, pattern string, tags []string) ([]Benchmark, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var selected []Benchmark
+	for _, b := range benchmarks {
+		if re.MatchString(b.Name) && hasAnyTag(b, tags) {
+			selected = append(selected, b)
+		}
+	}
+	return selected, nil
+}
+
+// splitTags splits a comma separated list of tags,
+// trimming spaces and dropping empty tags.
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func hasAnyTag(b Benchmark, tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, t := range tags {
+		for _, bt := range b.Tags {
+			if t == bt {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Thresholds fail the run if a benchmark breaches them.
+// Latencies are server-side, or client-side for benchmarks
+// without query stats. Zero values are not checked.
+type Thresholds struct {
+	MaxMedian          Duration `yaml:"max_median" json:"max_median,omitempty"`
+	MaxP99             Duration `yaml:"max_p99" json:"max_p99,omitempty"`
+	MaxMedianCPU       Duration `yaml:"max_median_cpu" json:"max_median_cpu,omitempty"`
+	MaxMedianOptimizer Duration `yaml:"max_median_optimizer" json:"max_median_optimizer,omitempty"`
+
+	// MaxRegressionPct is the tolerated increase of the
+	// median latency and CPU time compared to the -baseline
+	// results file, in percent. Only significant changes
+	// count as regressions.
+	MaxRegressionPct float64 `yaml:"max_regression_pct" json:"max_regression_pct,omitempty"`
+}
+
+// Duration is a time.Duration that is written as
+// a string like "50ms" in the config file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
 }
@@ -0,0 +1,98 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectBenchmarks(t *testing.T) {
+	benchmarks := []Benchmark{
+		{Name: "ReadUsers", Tags: []string{"read", "smoke"}},
+		{Name: "ReadOrders", Tags: []string{"read"}},
+		{Name: "WriteUsers", Tags: []string{"write"}},
+		{Name: "Untagged"},
+	}
+	tests := []struct {
+		pattern string
+		tags    []string
+		want    []string
+		wantErr bool
+	}{
+		{pattern: "", want: []string{"ReadUsers", "ReadOrders", "WriteUsers", "Untagged"}},
+		{pattern: "^Read", want: []string{"ReadUsers", "ReadOrders"}},
+		{pattern: "Users$", want: []string{"ReadUsers", "WriteUsers"}},
+		{tags: []string{"read"}, want: []string{"ReadUsers", "ReadOrders"}},
+		{tags: []string{"smoke", "write"}, want: []string{"ReadUsers", "WriteUsers"}},
+		{pattern: "Users", tags: []string{"read"}, want: []string{"ReadUsers"}},
+		{tags: []string{"missing"}, want: nil},
+		{pattern: "Nothing", want: nil},
+		{pattern: "(", wantErr: true},
+	}
+	for _, tt := range tests {
+		selected, err := selectBenchmarks(benchmarks, tt.pattern, tt.tags)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("selectBenchmarks(%q, %q) error = %v; want error %v", tt.pattern, tt.tags, err, tt.wantErr)
+			continue
+		}
+		var got []string
+		for _, b := range selected {
+			got = append(got, b.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectBenchmarks(%q, %q) = %q; want %q", tt.pattern, tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestHasAnyTag(t *testing.T) {
+	tests := []struct {
+		benchTags []string
+		tags      []string
+		want      bool
+	}{
+		{nil, nil, true},
+		{[]string{"read"}, nil, true},
+		{nil, []string{"read"}, false},
+		{[]string{"read"}, []string{"read"}, true},
+		{[]string{"read", "smoke"}, []string{"write", "smoke"}, true},
+		{[]string{"read"}, []string{"Read"}, false},
+	}
+	for _, tt := range tests {
+		if got := hasAnyTag(Benchmark{Tags: tt.benchTags}, tt.tags); got != tt.want {
+			t.Errorf("hasAnyTag(%q, %q) = %v; want %v", tt.benchTags, tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestSplitTags(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"read", []string{"read"}},
+		{"read,write", []string{"read", "write"}},
+		{" read , write ", []string{"read", "write"}},
+		{"read,,write,", []string{"read", "write"}},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		if got := splitTags(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitTags(%q) = %q; want %q", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudspannerecosystem/spanner-bench/internal/runner"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/stats"
+)
+
+// resultsFile is the document written with -o.
+type resultsFile struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	Database   string            `json:"database"`
+	Options    runOptions        `json:"options"`
+	Config     Config            `json:"config"`
+	Benchmarks []benchmarkExport `json:"benchmarks"`
+}
+
+// runOptions are the command line options
+// benchmarks were run with.
+type runOptions struct {
+	N              int           `json:"n"`
+	Count          int           `json:"count"`
+	Duration       time.Duration `json:"duration_ns,omitempty"`
+	Concurrency    int           `json:"concurrency"`
+	Stabilize      float64       `json:"stabilize,omitempty"`
+	MaxN           int           `json:"max_n,omitempty"`
+	MaxFailures    *int          `json:"max_failures,omitempty"`
+	Timeout        time.Duration `json:"timeout_ns,omitempty"`
+	WarmUp         int           `json:"warmup,omitempty"`
+	WarmUpDuration time.Duration `json:"warmup_duration_ns,omitempty"`
+	Bench          string        `json:"bench,omitempty"`
+	Tags           []string      `json:"tags,omitempty"`
+}
+
+// benchmarkExport is a run of a benchmark. All
+// durations are in nanoseconds.
+type benchmarkExport struct {
+	Name             string         `json:"name"`
+	OptimizerVersion string         `json:"optimizer_version,omitempty"`
+	Iterations       int            `json:"iterations"`
+	Duration         time.Duration  `json:"duration_ns"`
+	Throughput       float64        `json:"throughput"`
+	Errors           int            `json:"errors"`
+	ErrorCodes       map[string]int `json:"error_codes,omitempty"`
+	LastError        string         `json:"last_error,omitempty"`
+	TeardownErrors   int            `json:"teardown_errors,omitempty"`
+	LastTeardown     string         `json:"last_teardown_error,omitempty"`
+	Failure          string         `json:"failure,omitempty"`
+
+	// Server-side samples reported by query stats.
+	Elapsed   []int64 `json:"elapsed_ns"`
+	CPU       []int64 `json:"cpu_ns"`
+	Optimizer []int64 `json:"optimizer_ns"`
+
+	// RowsModified is the number of rows modified
+	// by DML or mutations in each run.
+	RowsModified []int64 `json:"rows_modified,omitempty"`
+
+	// Partitions is the number of partitions read by
+	// batch benchmarks and Partition is the latency of
+	// each partition. RowsRead is the number of rows read
+	// by batch and Read API benchmarks.
+	Partitions int     `json:"partitions,omitempty"`
+	Partition  []int64 `json:"partition_ns,omitempty"`
+	RowsRead   int64   `json:"rows_read,omitempty"`
+
+	// Statements are the samples of each statement
+	// if the benchmark runs more than one or DML.
+	Statements []statementExport `json:"statements,omitempty"`
+
+	// Client-side latencies.
+	Client []int64 `json:"client_ns"`
+	WarmUp []int64 `json:"warmup_ns,omitempty"`
+
+	Summary map[string]stats.Summary `json:"summary"`
+}
+
+// statementExport is a statement or a group of
+// batched DML statements of a benchmark run. The
+// samples are empty for statements without stats.
+type statementExport struct {
+	SQL          string  `json:"sql"`
+	DML          bool    `json:"dml,omitempty"`
+	Batch        bool    `json:"batch,omitempty"`
+	Elapsed      []int64 `json:"elapsed_ns"`
+	CPU          []int64 `json:"cpu_ns"`
+	Optimizer    []int64 `json:"optimizer_ns"`
+	RowsModified []int64 `json:"rows_modified,omitempty"`
+}
+
+func newResultsFile(c Config, opts runOptions, reports []*benchmarkReport) *resultsFile {
+	f := &resultsFile{
+		Timestamp:  time.Now().UTC(),
+		Database:   c.Database,
+		Options:    opts,
+		Config:     c,
+		Benchmarks: make([]benchmarkExport, 0, len(reports)),
+	}
+	for _, r := range reports {
+		f.Benchmarks = append(f.Benchmarks, exportReport(r))
+	}
+	return f
+}
+
+func exportReport(r *benchmarkReport) benchmarkExport {
+	e := benchmarkExport{
+		Name:             r.Name,
+		OptimizerVersion: optimizerVersion(r.bench),
+		Iterations:       len(r.run.Elapsed),
+		Duration:         r.run.Duration,
+		Throughput:       r.run.Throughput(),
+		Errors:           r.run.Errors,
+		Elapsed:          r.Elapsed,
+		CPU:              r.CPU,
+		Optimizer:        r.Optimizer,
+		Client:           r.run.Elapsed,
+		Summary: map[string]stats.Summary{
+			"latency":   stats.Summarize(r.Elapsed...),
+			"cpu":       stats.Summarize(r.CPU...),
+			"optimizer": stats.Summarize(r.Optimizer...),
+			"client":    stats.Summarize(r.run.Elapsed...),
+		},
+	}
+	if r.writes() {
+		e.RowsModified = r.Rows
+	}
+	if r.bench.Read != nil {
+		for _, n := range r.RowsRead {
+			e.RowsRead += n
+		}
+	}
+	if p := r.partitions; p != nil {
+		e.Partitions = p.Partitions
+		e.Partition = p.Elapsed
+		e.RowsRead = p.Rows
+		e.Summary["partition"] = stats.Summarize(p.Elapsed...)
+	}
+	if len(r.steps) > 1 || r.hasDML() {
+		e.Statements = exportSteps(r)
+	}
+	if len(r.run.Codes) > 0 {
+		e.ErrorCodes = make(map[string]int, len(r.run.Codes))
+		for c, n := range r.run.Codes {
+			e.ErrorCodes[c.String()] = n
+		}
+	}
+	if r.run.LastErr != nil {
+		e.LastError = r.run.LastErr.Error()
+	}
+	if r.run.TeardownErrors > 0 {
+		e.TeardownErrors = r.run.TeardownErrors
+		e.LastTeardown = r.run.LastTeardownErr.Error()
+	}
+	if err := r.Err(); err != nil {
+		e.Failure = err.Error()
+	}
+	if r.warmUp != nil {
+		e.WarmUp = r.warmUp.Elapsed
+	}
+	return e
+}
+
+func exportSteps(r *benchmarkReport) []statementExport {
+	stmts := parseSQL(r.bench.SQL)
+	steps := planSteps(stmts, r.bench.BatchDML)
+
+	var exports []statementExport
+	for i, s := range r.steps {
+		if i >= len(steps) {
+			break
+		}
+		sql := make([]string, 0, steps[i].end-steps[i].start)
+		for _, stmt := range stmts[steps[i].start:steps[i].end] {
+			sql = append(sql, strings.TrimSpace(stmt.SQL))
+		}
+		e := statementExport{
+			SQL:       strings.Join(sql, "; "),
+			DML:       steps[i].dml,
+			Batch:     steps[i].batch(),
+			Elapsed:   s.Elapsed,
+			CPU:       s.CPU,
+			Optimizer: s.Optimizer,
+		}
+		if steps[i].dml {
+			e.RowsModified = s.Rows
+		}
+		exports = append(exports, e)
+	}
+	return exports
+}
+
+// optimizerVersion returns the optimizer version the
+// benchmark is run with, if it is set either in the
+// config or in the environment.
+func optimizerVersion(bench Benchmark) string {
+	if bench.Optimizer != "" {
+		return bench.Optimizer
+	}
+	return os.Getenv("SPANNER_OPTIMIZER_VERSION")
+}
+
+func newRunOptions(cfg runner.Config, count int) runOptions {
+	return runOptions{
+		N:              cfg.N,
+		Count:          count,
+		Duration:       cfg.Duration,
+		Concurrency:    cfg.Concurrency,
+		Stabilize:      cfg.Stabilize,
+		MaxN:           cfg.MaxN,
+		MaxFailures:    cfg.MaxFailures,
+		Timeout:        cfg.Timeout,
+		WarmUp:         cfg.WarmUp,
+		WarmUpDuration: cfg.WarmUpDuration,
+	}
+}
+
+func writeResults(path string, f *resultsFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+)
+
+// Hook prepares or cleans up data outside of the
+// timed region of a benchmark. Its DML and mutations
+// run in a read-write transaction.
+type Hook struct {
+	SQL       string     `yaml:"sql" json:"sql,omitempty"` // DML statements
+	Mutations *Mutations `yaml:"mutations" json:"mutations,omitempty"`
+
+	// Each runs the hook around each run instead
+	// of once per benchmark.
+	Each bool `yaml:"each" json:"each,omitempty"`
+}
+
+func (h *Hook) validate() error {
+	stmts := parseSQL(h.SQL)
+	if len(stmts) == 0 && h.Mutations == nil {
+		return errors.New("no sql or mutations")
+	}
+	for _, stmt := range stmts {
+		if !isDML(stmt.SQL) {
+			return fmt.Errorf("%q is not DML", stmt.SQL)
+		}
+	}
+	if h.Mutations != nil {
+		if h.Mutations.Apply {
+			return errors.New("apply is not supported in hooks")
+		}
+		if _, err := h.Mutations.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compile returns a function that runs the hook.
+func (h *Hook) compile(client *spanner.Client) (func(ctx context.Context) error, error) {
+	stmts := parseSQL(h.SQL)
+	mutations := func() []*spanner.Mutation { return nil }
+	if h.Mutations != nil {
+		var err error
+		if mutations, err = h.Mutations.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return func(ctx context.Context) error {
+		ms := mutations()
+		_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+			if len(stmts) > 0 {
+				if _, err := tx.BatchUpdate(ctx, stmts); err != nil {
+					return err
+				}
+			}
+			return tx.BufferWrite(ms)
+		})
+		return err
+	}, nil
+}
@@ -20,8 +20,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"time"
 
 	"cloud.google.com/go/spanner"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/runner"
 	"google.golang.org/api/option"
 	"gopkg.in/yaml.v2"
 )
@@ -29,18 +32,83 @@ import (
 const userAgent = "spannerbench/0.1"
 
 var (
-	config string
-	n      int // number of iterations for each
+	config         string
+	n              int // number of iterations for each
+	duration       time.Duration
+	concurrency    int
+	stabilize      float64
+	maxN           int
+	percentile     float64
+	maxFailures    int
+	timeout        time.Duration // per iteration
+	runTimeout     time.Duration // for all benchmarks
+	warmUp         int
+	warmUpDuration time.Duration
+	sessions       int
+	count          int
+	format         string
+	output         string
+	baseline       string
+	bench          string
+	tags           string
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		compareMain(os.Args[2:])
+		return
+	}
+
 	ctx := context.Background()
 	flag.StringVar(&config, "f", "benchmark.yaml", "")
 	flag.IntVar(&n, "n", 50, "")
+	flag.DurationVar(&duration, "duration", 0, "")
+	flag.IntVar(&concurrency, "c", 1, "")
+	flag.Float64Var(&stabilize, "stabilize", 0, "")
+	flag.IntVar(&maxN, "max-n", 0, "")
+	flag.Float64Var(&percentile, "percentile", 50, "")
+	flag.IntVar(&maxFailures, "max-failures", 0, "")
+	flag.DurationVar(&timeout, "timeout", 0, "")
+	flag.DurationVar(&runTimeout, "run-timeout", 0, "")
+	flag.IntVar(&warmUp, "warmup", 0, "")
+	flag.DurationVar(&warmUpDuration, "warmup-duration", 0, "")
+	flag.IntVar(&sessions, "sessions", 0, "")
+	flag.IntVar(&count, "count", 1, "")
+	flag.StringVar(&format, "format", formatText, "")
+	flag.StringVar(&output, "o", "", "")
+	flag.StringVar(&baseline, "baseline", "", "")
+	flag.StringVar(&bench, "bench", "", "")
+	flag.StringVar(&tags, "tags", "", "")
 	flag.Usage = func() {
 		fmt.Println(usageText)
 	}
 	flag.Parse()
+	// Zero is a valid budget, so the default
+	// only applies if the flag isn't set.
+	var failureBudget *int
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "max-failures" {
+			failureBudget = &maxFailures
+		}
+	})
+
+	if format != formatText && format != formatBench {
+		log.Fatalf("Unknown output format %q, use %q or %q", format, formatText, formatBench)
+	}
+
+	var base *resultsFile
+	if baseline != "" {
+		var err error
+		if base, err = readResults(baseline); err != nil {
+			log.Fatalf("Cannot read the baseline file: %v", err)
+		}
+	}
+
+	if runTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, runTimeout)
+		defer cancel()
+	}
 
 	data, err := ioutil.ReadFile(config)
 	if err != nil {
@@ -51,6 +119,17 @@ func main() {
 	if err := yaml.Unmarshal(data, &c); err != nil {
 		log.Fatalf("Cannot parse the config file: %v", err)
 	}
+	if err := c.validate(); err != nil {
+		log.Fatalf("Invalid config file: %v", err)
+	}
+	tagList := splitTags(tags)
+	selected, err := selectBenchmarks(c.Benchmarks, bench, tagList)
+	if err != nil {
+		log.Fatalf("Invalid -bench: %v", err)
+	}
+	if len(selected) == 0 {
+		log.Fatalf("No benchmarks match -bench %q and -tags %q", bench, tags)
+	}
 
 	client, err := spanner.NewClient(ctx, c.Database, option.WithUserAgent(userAgent))
 	if err != nil {
@@ -58,15 +137,80 @@ func main() {
 	}
 
 	b := benchmarks{
-		client:     client,
-		n:          n,
-		benchmarks: c.Benchmarks,
+		client: client,
+		config: runner.Config{
+			N:              n,
+			Duration:       duration,
+			Concurrency:    concurrency,
+			Stabilize:      stabilize,
+			MaxN:           maxN,
+			Percentile:     percentile,
+			MaxFailures:    failureBudget,
+			Timeout:        timeout,
+			WarmUp:         warmUp,
+			WarmUpDuration: warmUpDuration,
+		},
+		sessions:   sessions,
+		count:      count,
+		format:     format,
+		benchmarks: selected,
+	}
+	reports := b.start(ctx)
+
+	opts := newRunOptions(b.config, count)
+	opts.Bench, opts.Tags = bench, tagList
+	results := newResultsFile(c, opts, reports)
+	if output != "" {
+		if err := writeResults(output, results); err != nil {
+			log.Fatalf("Cannot write the results file: %v", err)
+		}
+	}
+	if breaches := checkThresholds(selected, results, base); len(breaches) > 0 {
+		fmt.Fprintln(os.Stderr, "Thresholds breached:")
+		for _, b := range breaches {
+			fmt.Fprintf(os.Stderr, "  %v\n", b)
+		}
+		os.Exit(1)
 	}
-	b.start()
 }
 
 const usageText = `spannerbench [options...]
+spannerbench compare [options...] old.json new.json
 
 Options:
 -f   Config file to read from, by default "benchmark.yaml". 
--n   Number of times to run a query, by default 20.`
+-n   Number of times to run a query, by default 20.
+-duration      Runs each benchmark for the given duration, e.g. 30s,
+               instead of a fixed number of times. Overrides -n.
+-stabilize     Keeps running after -n runs until the 95% confidence
+               interval of the -percentile latency is narrower than
+               the given ratio of it, e.g. 0.05.
+-max-n         Maximum number of runs with -stabilize, by default 10*n.
+-percentile    Percentile -stabilize looks at, by default 50.
+-c   Number of runs in parallel for each benchmark, by default 1.
+     Can be overridden by concurrency in the config file.
+-max-failures  Number of failed runs tolerated per benchmark before
+               it is abandoned, by default 2*n. Zero tolerates no
+               failures, negative values never abandon a benchmark.
+-timeout       Deadline of each run, e.g. 2s. No deadline by default.
+-run-timeout   Deadline for running all benchmarks. Benchmarks
+               still running or not started yet fail when it expires.
+-warmup        Number of untimed runs before each benchmark.
+-warmup-duration  Duration of untimed runs before each benchmark.
+-sessions      Number of sessions to create before benchmarking.
+-count         Number of times to run each benchmark, by default 1.
+-format        Output format, "text" (default) or "bench" to print
+               a line per run in the Go benchmark format for benchstat.
+-o             JSON file to write the config, run metadata, raw
+               samples and summaries to, e.g. results.json.
+-baseline      Results file to check max_regression_pct thresholds
+               against.
+-bench         Runs only the benchmarks whose name matches the
+               regular expression.
+-tags          Runs only the benchmarks with any of the comma
+               separated tags in the config file.
+
+See the README for the format of the config file.
+
+Exits with status 1 if a benchmark fails or breaches any of
+its thresholds in the config file.`
@@ -0,0 +1,98 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+// Mutations configures the rows a benchmark writes
+// with mutations.
+type Mutations struct {
+	Table string `yaml:"table" json:"table"`
+
+	// Op is one of insert (default), update,
+	// insert_or_update or replace.
+	Op string `yaml:"op" json:"op,omitempty"`
+
+	// Columns generate the value of each column.
+	Columns map[string]Value `yaml:"columns" json:"columns"`
+
+	// BatchSize is the number of rows written
+	// in each run, by default 1.
+	BatchSize int `yaml:"batch_size" json:"batch_size,omitempty"`
+
+	// Apply makes the benchmark write blindly with
+	// client.Apply instead of buffering the mutations
+	// in a read-write transaction.
+	Apply bool `yaml:"apply" json:"apply,omitempty"`
+}
+
+var mutationOps = map[string]func(table string, columns []string, values []interface{}) *spanner.Mutation{
+	"insert":           spanner.Insert,
+	"update":           spanner.Update,
+	"insert_or_update": spanner.InsertOrUpdate,
+	"replace":          spanner.Replace,
+}
+
+// compile returns a function that generates
+// the mutations of a run.
+func (m *Mutations) compile() (func() []*spanner.Mutation, error) {
+	if m.Table == "" {
+		return nil, errors.New("mutations require a table")
+	}
+	if len(m.Columns) == 0 {
+		return nil, errors.New("mutations require columns")
+	}
+	op := strings.ToLower(m.Op)
+	if op == "" {
+		op = "insert"
+	}
+	newMutation, ok := mutationOps[op]
+	if !ok {
+		return nil, fmt.Errorf("unknown mutation op %q", m.Op)
+	}
+	values, err := newParamSet(m.Columns)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, 0, len(m.Columns))
+	for c := range m.Columns {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	batchSize := m.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return func() []*spanner.Mutation {
+		ms := make([]*spanner.Mutation, batchSize)
+		for i := range ms {
+			row := values.next()
+			vals := make([]interface{}, len(columns))
+			for j, c := range columns {
+				vals[j] = row[c]
+			}
+			ms[i] = newMutation(m.Table, columns, vals)
+		}
+		return ms
+	}, nil
+}
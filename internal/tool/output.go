@@ -0,0 +1,217 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/benchfmt"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/histogram"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/stats"
+)
+
+const (
+	formatText  = "text"
+	formatBench = "bench"
+)
+
+// printHeader prints the name of the benchmark, its
+// timestamp bound, if any, and whether it's single-use.
+func printHeader(bench Benchmark) {
+	header := []interface{}{bench.Name}
+	if tb := bench.timestampBound(); tb != nil {
+		header = append(header, tb)
+	}
+	if bench.singleUse() {
+		header = append(header, "(single-use)")
+	}
+	if bench.Partitioned {
+		header = append(header, "(partitioned)")
+	}
+	if bench.Batch {
+		header = append(header, "(batch)")
+	}
+	fmt.Println(header...)
+}
+
+func printText(report *benchmarkReport) {
+	cfg := report.config
+	if w := report.warmUp; w != nil {
+		fmt.Printf("  %-10v: %v runs, median %v (client-side)\n", "Warm-up", len(w.Elapsed), time.Duration(stats.MedianInt64(w.Elapsed...)))
+	}
+	if r := report.run; cfg.Duration > 0 || cfg.Rate > 0 {
+		fmt.Printf("  %-10v: %v in %v (%.2f runs/s)\n", "Runs", len(r.Elapsed), r.Duration, r.Throughput())
+	}
+	if cfg.Rate > 0 {
+		// Server-side latency doesn't include queueing delay.
+		fmt.Printf("  %-10v: %.2f runs/s, median client latency %v\n", "Rate", cfg.Rate, time.Duration(stats.MedianInt64(report.run.Elapsed...)))
+	}
+	if r := report.run; cfg.Stabilize > 0 {
+		fmt.Printf("  %-10v: %v runs, client %v\n", "Confidence", len(r.Elapsed), r.IntervalString())
+	}
+	if len(report.run.Elapsed) > 0 {
+		printSummaries(report)
+	}
+	if len(report.steps) > 1 || report.hasDML() {
+		printSteps(report)
+	}
+	if p := report.partitions; p != nil {
+		fmt.Printf("  %-10v: %.1f per run, %v rows, %.2f rows/s\n", "Partitions",
+			float64(p.Partitions)/float64(p.Runs), p.Rows, float64(p.Rows)/report.run.Duration.Seconds())
+	}
+	if report.bench.Read != nil && !report.bench.Batch && len(report.RowsRead) > 0 {
+		fmt.Printf("  %-10v: %.1f read per run\n", "Rows", stats.MeanInt64(report.RowsRead...))
+	}
+	if report.writes() && len(report.Rows) > 0 {
+		fmt.Printf("  %-10v: %.1f modified per run\n", "Rows", stats.MeanInt64(report.Rows...))
+	}
+	if r := report.run; r.Errors > 0 {
+		fmt.Printf("  %-10v: %v (%v)\n", "Errors", r.Errors, r.CodesString())
+		fmt.Printf("  %-10v: %v\n", "Last error", r.LastErr)
+	}
+	if r := report.run; r.TeardownErrors > 0 {
+		fmt.Printf("  %-10v: %v failed, last: %v\n", "Teardown", r.TeardownErrors, r.LastTeardownErr)
+	}
+	if report.Err() != nil {
+		fmt.Printf("  FAIL: %v\n", report.Err())
+	}
+	if histogram := histogram.NewHistogram(report.Elapsed); histogram != nil {
+		fmt.Println("Latency histogram:")
+		fmt.Println(histogram)
+	}
+}
+
+// printBench prints the report as a Go benchmark line
+// with the mean server-side latency, CPU and optimizer
+// time per run, if there are stats, and the mean
+// client-side latency. Runs without any samples are
+// skipped.
+func printBench(report *benchmarkReport) {
+	if len(report.run.Elapsed) == 0 {
+		return
+	}
+	var metrics []benchfmt.Metric
+	if len(report.Elapsed) > 0 {
+		metrics = append(metrics,
+			benchfmt.Metric{Value: stats.MeanInt64(report.Elapsed...), Unit: "ns/op"},
+			benchfmt.Metric{Value: stats.MeanInt64(report.CPU...), Unit: "cpu-ns/op"},
+			benchfmt.Metric{Value: stats.MeanInt64(report.Optimizer...), Unit: "optimizer-ns/op"},
+		)
+	}
+	metrics = append(metrics, benchfmt.Metric{Value: stats.MeanInt64(report.run.Elapsed...), Unit: "client-ns/op"})
+	if report.writes() {
+		metrics = append(metrics, benchfmt.Metric{Value: stats.MeanInt64(report.Rows...), Unit: "rows/op"})
+	}
+	if p := report.partitions; p != nil {
+		metrics = append(metrics,
+			benchfmt.Metric{Value: float64(p.Partitions) / float64(p.Runs), Unit: "partitions/op"},
+			benchfmt.Metric{Value: stats.MeanInt64(p.Elapsed...), Unit: "partition-ns/op"},
+			benchfmt.Metric{Value: float64(p.Rows) / report.run.Duration.Seconds(), Unit: "rows/s"},
+		)
+	}
+	benchfmt.Write(os.Stdout, report.Name, len(report.run.Elapsed), metrics...)
+}
+
+// printSteps prints the median latencies and mean
+// rows modified of each statement of the report.
+// Statements without stats show "-".
+func printSteps(report *benchmarkReport) {
+	stmts := parseSQL(report.bench.SQL)
+	steps := planSteps(stmts, report.bench.BatchDML)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "  Statement\tlatency\tCPU time\toptimizer\trows\t")
+	for i, s := range report.steps {
+		if i >= len(steps) {
+			break
+		}
+		fmt.Fprintf(w, "  %v\t%v\t%v\t%v\t%.1f\t\n", stepLabel(stmts, steps[i]),
+			median(s.Elapsed), median(s.CPU), median(s.Optimizer),
+			stats.MeanInt64(s.Rows...))
+	}
+	w.Flush()
+}
+
+// median returns the median of x as a duration,
+// or "-" if x is empty.
+func median(x []int64) string {
+	if len(x) == 0 {
+		return "-"
+	}
+	return time.Duration(stats.MedianInt64(x...)).String()
+}
+
+// stepLabel returns a short description of s.
+func stepLabel(stmts []spanner.Statement, s step) string {
+	if s.batch() {
+		return fmt.Sprintf("BATCH DML (%d statements)", s.end-s.start)
+	}
+	const max = 40
+	label := strings.Join(strings.Fields(stmts[s.start].SQL), " ")
+	if len(label) > max {
+		label = label[:max-3] + "..."
+	}
+	return label
+}
+
+func printSummaries(report *benchmarkReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "  \tmin\tp50\tp90\tp95\tp99\tp99.9\tmax\tmean\tstddev\t")
+	type row struct {
+		name    string
+		samples []int64
+	}
+	rows := []row{
+		{"Latency", report.Elapsed},
+		{"CPU time", report.CPU},
+		{"Optimizer", report.Optimizer},
+		{"Client", report.run.Elapsed},
+	}
+	if p := report.partitions; p != nil {
+		rows = append(rows, row{"Partition", p.Elapsed})
+	}
+	for _, row := range rows {
+		if len(row.samples) == 0 {
+			continue
+		}
+		s := stats.Summarize(row.samples...)
+		fmt.Fprintf(w, "  %v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t\n", row.name,
+			time.Duration(s.Min), time.Duration(s.P50), time.Duration(s.P90),
+			time.Duration(s.P95), time.Duration(s.P99), time.Duration(s.P999),
+			time.Duration(s.Max), time.Duration(s.Mean), time.Duration(s.StdDev))
+	}
+	w.Flush()
+}
+
+func printFailures(reports []*benchmarkReport) {
+	var failed []*benchmarkReport
+	for _, r := range reports {
+		if r.Err() != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	fmt.Printf("%v of %v benchmarks failed:\n", len(failed), len(reports))
+	for _, r := range failed {
+		fmt.Printf("  %v: %v (%v)\n", r.Name, r.Err(), r.run.CodesString())
+	}
+}
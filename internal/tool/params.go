@@ -0,0 +1,95 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// paramSet generates query parameters for each run.
+// It is safe for concurrent use.
+type paramSet struct {
+	mu   sync.Mutex
+	gens map[string]generator
+}
+
+func newParamSet(params map[string]Value) (*paramSet, error) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	p := &paramSet{gens: make(map[string]generator, len(params))}
+	for name, v := range params {
+		gen, err := v.compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("param %q: %v", name, err)
+		}
+		p.gens[name] = gen
+	}
+	return p, nil
+}
+
+// next generates a new value for each parameter.
+func (p *paramSet) next() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	values := make(map[string]interface{}, len(p.gens))
+	for name, gen := range p.gens {
+		values[name] = gen()
+	}
+	return values
+}
+
+var paramRef = regexp.MustCompile(`@(\w+)`)
+
+// statementParams returns the names of the parameters
+// each statement refers to.
+func statementParams(stmts []spanner.Statement, params map[string]Value) [][]string {
+	names := make([][]string, len(stmts))
+	for i, stmt := range stmts {
+		seen := make(map[string]bool)
+		for _, m := range paramRef.FindAllStringSubmatch(stmt.SQL, -1) {
+			name := m[1]
+			if _, ok := params[name]; ok && !seen[name] {
+				seen[name] = true
+				names[i] = append(names[i], name)
+			}
+		}
+		sort.Strings(names[i])
+	}
+	return names
+}
+
+// bind returns the statements with the parameters they
+// refer to set from values.
+func bind(stmts []spanner.Statement, names [][]string, values map[string]interface{}) []spanner.Statement {
+	bound := make([]spanner.Statement, len(stmts))
+	for i, stmt := range stmts {
+		bound[i] = spanner.Statement{SQL: stmt.SQL}
+		if len(names[i]) == 0 {
+			continue
+		}
+		bound[i].Params = make(map[string]interface{}, len(names[i]))
+		for _, name := range names[i] {
+			bound[i].Params[name] = values[name]
+		}
+	}
+	return bound
+}
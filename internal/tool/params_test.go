@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestStatementParams(t *testing.T) {
+	params := map[string]Value{
+		"id":   {Type: "INT64", Value: 1},
+		"name": {Type: "STRING", Value: "gopher"},
+	}
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT 1", nil},
+		{"SELECT * FROM Users WHERE UserId = @id", []string{"id"}},
+		{"SELECT * FROM Users WHERE Name = @name AND UserId = @id", []string{"id", "name"}},
+		{"SELECT * FROM Users WHERE UserId = @id OR ManagerId = @id", []string{"id"}},
+		{"SELECT * FROM Users WHERE UserId = @unknown", nil},
+		{"SELECT * FROM Users WHERE UserId = @identifier", nil},
+	}
+	for _, tt := range tests {
+		got := statementParams([]spanner.Statement{spanner.NewStatement(tt.sql)}, params)
+		if !reflect.DeepEqual(got[0], tt.want) {
+			t.Errorf("statementParams(%q) = %q; want %q", tt.sql, got[0], tt.want)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	stmts := []spanner.Statement{
+		spanner.NewStatement("SELECT 1"),
+		spanner.NewStatement("SELECT * FROM Users WHERE UserId = @id"),
+		spanner.NewStatement("UPDATE Users SET Name = @name WHERE UserId = @id"),
+	}
+	names := [][]string{nil, {"id"}, {"id", "name"}}
+	values := map[string]interface{}{"id": int64(7), "name": "gopher", "unused": true}
+
+	got := bind(stmts, names, values)
+	want := []spanner.Statement{
+		{SQL: stmts[0].SQL},
+		{SQL: stmts[1].SQL, Params: map[string]interface{}{"id": int64(7)}},
+		{SQL: stmts[2].SQL, Params: map[string]interface{}{"id": int64(7), "name": "gopher"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bind() = %+v; want %+v", got, want)
+	}
+	for _, stmt := range stmts {
+		if len(stmt.Params) > 0 {
+			t.Errorf("bind() modified %q", stmt.SQL)
+		}
+	}
+}
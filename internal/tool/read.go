@@ -0,0 +1,206 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+// Read configures a read of a table with the Read
+// API instead of SQL.
+type Read struct {
+	Table   string   `yaml:"table" json:"table"`
+	Index   string   `yaml:"index" json:"index,omitempty"` // read the index instead of the table
+	Columns []string `yaml:"columns" json:"columns"`
+	Keys    KeySet   `yaml:"keys" json:"keys"`
+	Limit   int      `yaml:"limit" json:"limit,omitempty"` // maximum number of rows, zero means no limit
+}
+
+// KeySet describes the keys to read. Keys are
+// generated for each run.
+type KeySet struct {
+	// All reads all the rows.
+	All bool `yaml:"all" json:"all,omitempty"`
+
+	// Key generates each part of a key. Count keys
+	// are generated, by default 1.
+	Key   []Value `yaml:"key" json:"key,omitempty"`
+	Count int     `yaml:"count" json:"count,omitempty"`
+
+	Ranges []KeyRange `yaml:"ranges" json:"ranges,omitempty"`
+}
+
+// KeyRange is a range of keys. Kind is one of closed_open
+// (default), closed_closed, open_closed or open_open.
+type KeyRange struct {
+	Start []Value `yaml:"start" json:"start"`
+	End   []Value `yaml:"end" json:"end"`
+	Kind  string  `yaml:"kind" json:"kind,omitempty"`
+}
+
+var keyRangeKinds = map[string]spanner.KeyRangeKind{
+	"closed_open":   spanner.ClosedOpen,
+	"closed_closed": spanner.ClosedClosed,
+	"open_closed":   spanner.OpenClosed,
+	"open_open":     spanner.OpenOpen,
+}
+
+// keySetGen generates key sets.
+// It is safe for concurrent use.
+type keySetGen struct {
+	mu     sync.Mutex
+	all    bool
+	key    []generator
+	count  int
+	ranges []keyRangeGen
+}
+
+type keyRangeGen struct {
+	start, end []generator
+	kind       spanner.KeyRangeKind
+}
+
+func (k *KeySet) compile() (*keySetGen, error) {
+	if !k.All && len(k.Key) == 0 && len(k.Ranges) == 0 {
+		return nil, errors.New("no keys to read")
+	}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	g := &keySetGen{all: k.All, count: k.Count}
+	if g.count < 1 {
+		g.count = 1
+	}
+	var err error
+	if g.key, err = compileKey(r, k.Key); err != nil {
+		return nil, fmt.Errorf("key: %v", err)
+	}
+	for i, kr := range k.Ranges {
+		kind, ok := keyRangeKinds[strings.ToLower(kr.Kind)]
+		if kr.Kind == "" {
+			kind, ok = spanner.ClosedOpen, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("range %d: unknown kind %q", i, kr.Kind)
+		}
+		rg := keyRangeGen{kind: kind}
+		if rg.start, err = compileKey(r, kr.Start); err != nil {
+			return nil, fmt.Errorf("range %d start: %v", i, err)
+		}
+		if rg.end, err = compileKey(r, kr.End); err != nil {
+			return nil, fmt.Errorf("range %d end: %v", i, err)
+		}
+		g.ranges = append(g.ranges, rg)
+	}
+	return g, nil
+}
+
+func compileKey(r *rand.Rand, parts []Value) ([]generator, error) {
+	gens := make([]generator, len(parts))
+	for i, v := range parts {
+		gen, err := v.compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %v", i, err)
+		}
+		gens[i] = gen
+	}
+	return gens, nil
+}
+
+func key(gens []generator) spanner.Key {
+	k := make(spanner.Key, len(gens))
+	for i, gen := range gens {
+		k[i] = gen()
+	}
+	return k
+}
+
+// next generates a new key set.
+func (g *keySetGen) next() spanner.KeySet {
+	if g.all {
+		return spanner.AllKeys()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var sets []spanner.KeySet
+	if len(g.key) > 0 {
+		for i := 0; i < g.count; i++ {
+			sets = append(sets, key(g.key))
+		}
+	}
+	for _, r := range g.ranges {
+		sets = append(sets, spanner.KeyRange{
+			Start: key(r.start),
+			End:   key(r.end),
+			Kind:  r.kind,
+		})
+	}
+	return spanner.KeySets(sets...)
+}
+
+// compileKeys compiles the keys of r, if it's not nil.
+func compileKeys(r *Read) (*keySetGen, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return r.Keys.compile()
+}
+
+// reader is implemented by read-only and
+// read-write transactions.
+type reader interface {
+	ReadWithOptions(ctx context.Context, table string, keys spanner.KeySet, columns []string, opts *spanner.ReadOptions) *spanner.RowIterator
+}
+
+// read reads the keys and returns the number of rows read.
+func (r *Read) read(ctx context.Context, tx reader, keys spanner.KeySet) (benchmarkResult, error) {
+	it := tx.ReadWithOptions(ctx, r.Table, keys, r.Columns, &spanner.ReadOptions{
+		Index: r.Index,
+		Limit: r.Limit,
+	})
+	defer it.Stop()
+
+	var rows int64
+	for {
+		_, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return benchmarkResult{}, err
+		}
+		rows++
+	}
+	return benchmarkResult{RowsRead: rows}, nil
+}
+
+func (r *Read) validate() error {
+	if r.Table == "" {
+		return errors.New("read requires a table")
+	}
+	if len(r.Columns) == 0 {
+		return errors.New("read requires columns")
+	}
+	_, err := r.Keys.compile()
+	return err
+}
@@ -19,6 +19,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/cloudspannerecosystem/spanner-bench/internal/batchread"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/runner"
 )
 
 func parseInt64(v string) int64 {
@@ -35,14 +38,120 @@ func parseDuration(v string) time.Duration {
 	return dur
 }
 
+// benchmarkResult is the result of a run or of a step of it.
+// Elapsed, CPUElapsed and OptimizerElapsed are server-side and
+// come from the query stats. DML run with Update, batch DML,
+// Read API reads, mutations, partitioned DML and batch reads
+// don't return stats, so runs that include any of them leave
+// the three empty and only have the client-side latency
+// measured by the runner.
 type benchmarkResult struct {
 	Elapsed          time.Duration
 	CPUElapsed       time.Duration
 	OptimizerElapsed time.Duration
+	RowsModified     int64
+	RowsRead         int64 // by the Read API
+
+	stats bool              // whether the durations are set
+	steps []benchmarkResult // results of each step, if any
+	batch *batchread.Result // partitions read, if any
+}
+
+// add adds the result of the next step. The sum
+// only has stats if every step has.
+func (b *benchmarkResult) add(r benchmarkResult) {
+	b.stats = r.stats && (b.stats || len(b.steps) == 0)
+	b.Elapsed += r.Elapsed
+	b.CPUElapsed += r.CPUElapsed
+	b.OptimizerElapsed += r.OptimizerElapsed
+	b.RowsModified += r.RowsModified
+	b.RowsRead += r.RowsRead
+	b.steps = append(b.steps, r)
 }
 
 func (b benchmarkResult) String() string {
 	buf := &strings.Builder{}
-	fmt.Fprintf(buf, "%v %v %v", b.Elapsed, b.CPUElapsed, b.OptimizerElapsed)
+	fmt.Fprintf(buf, "%v %v %v %v", b.Elapsed, b.CPUElapsed, b.OptimizerElapsed, b.RowsModified)
 	return buf.String()
 }
+
+// benchmarkReport contains the samples collected
+// for a benchmark in nanoseconds. Elapsed, CPU and
+// Optimizer only have samples of runs with stats.
+type benchmarkReport struct {
+	Name      string
+	Elapsed   []int64
+	CPU       []int64
+	Optimizer []int64
+	Rows      []int64 // rows modified
+	RowsRead  []int64 // rows read by the Read API
+
+	steps      []*stepReport
+	partitions *batchread.Stats // nil unless the benchmark is a batch read
+	bench      Benchmark
+	config     runner.Config
+	warmUp     *runner.Result // nil if there was no warm-up
+	run        *runner.Result
+}
+
+func (r *benchmarkReport) add(result benchmarkResult) {
+	if result.stats {
+		r.Elapsed = append(r.Elapsed, int64(result.Elapsed))
+		r.CPU = append(r.CPU, int64(result.CPUElapsed))
+		r.Optimizer = append(r.Optimizer, int64(result.OptimizerElapsed))
+	}
+	r.Rows = append(r.Rows, result.RowsModified)
+	r.RowsRead = append(r.RowsRead, result.RowsRead)
+	if result.batch != nil {
+		if r.partitions == nil {
+			r.partitions = &batchread.Stats{}
+		}
+		r.partitions.Add(result.batch)
+	}
+	for i, step := range result.steps {
+		if i == len(r.steps) {
+			r.steps = append(r.steps, &stepReport{})
+		}
+		r.steps[i].add(step)
+	}
+}
+
+// writes reports whether the benchmark modifies
+// rows with DML or mutations.
+func (r *benchmarkReport) writes() bool {
+	return r.bench.Mutations != nil || r.hasDML()
+}
+
+// hasDML reports whether the benchmark runs DML.
+func (r *benchmarkReport) hasDML() bool {
+	for _, s := range planSteps(parseSQL(r.bench.SQL), false) {
+		if s.dml {
+			return true
+		}
+	}
+	return false
+}
+
+// stepReport contains the samples collected for
+// a step of a benchmark.
+type stepReport struct {
+	Elapsed   []int64
+	CPU       []int64
+	Optimizer []int64
+	Rows      []int64
+}
+
+func (r *stepReport) add(result benchmarkResult) {
+	if result.stats {
+		r.Elapsed = append(r.Elapsed, int64(result.Elapsed))
+		r.CPU = append(r.CPU, int64(result.CPUElapsed))
+		r.Optimizer = append(r.Optimizer, int64(result.OptimizerElapsed))
+	}
+	r.Rows = append(r.Rows, result.RowsModified)
+}
+
+// Err returns a non-nil error if the benchmark
+// was abandoned after too many failures.
+func (r *benchmarkReport) Err() error {
+	return r.run.Err
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudspannerecosystem/spanner-bench/internal/stats"
+)
+
+// regressionAlpha is the significance level a regression
+// against the baseline needs to count as a breach.
+const regressionAlpha = 0.05
+
+// checkThresholds returns a message for each threshold
+// breached by the results. Runs of the same benchmark
+// are pooled. Benchmarks that failed or have thresholds
+// but no samples are reported as breaches too. Latency
+// thresholds are checked against the client-side latency
+// of benchmarks without server-side stats. baseline can
+// be nil.
+func checkThresholds(benchmarks []Benchmark, results, baseline *resultsFile) []string {
+	var breaches []string
+	failed := make(map[string]bool)
+	for _, b := range results.Benchmarks {
+		if b.Failure != "" {
+			breaches = append(breaches, fmt.Sprintf("%v: failed: %v", b.Name, b.Failure))
+			failed[b.Name] = true
+		}
+	}
+
+	groups, _ := groupByName(results)
+	var baseGroups map[string]*samples
+	if baseline != nil {
+		baseGroups, _ = groupByName(baseline)
+	}
+	for _, bench := range benchmarks {
+		t := bench.Thresholds
+		if t == nil || failed[bench.Name] {
+			continue
+		}
+		s := groups[bench.Name]
+		if s == nil || len(s.Client) == 0 {
+			breaches = append(breaches, fmt.Sprintf("%v: no samples to check thresholds against", bench.Name))
+			continue
+		}
+		check := func(what string, samples []int64, got int64, limit Duration, name string) {
+			if limit <= 0 {
+				return
+			}
+			if len(samples) == 0 {
+				breaches = append(breaches, fmt.Sprintf("%v: no server-side samples to check %v against", bench.Name, name))
+				return
+			}
+			if got > int64(limit) {
+				breaches = append(breaches, fmt.Sprintf("%v: %v %v > %v %v",
+					bench.Name, what, time.Duration(got), name, time.Duration(limit)))
+			}
+		}
+		latency, what := s.Elapsed, "latency"
+		if len(latency) == 0 {
+			latency, what = s.Client, "client latency"
+		}
+		check("median "+what, latency, stats.MedianInt64(latency...), t.MaxMedian, "max_median")
+		check("p99 "+what, latency, stats.PercentileInt64(99, latency...), t.MaxP99, "max_p99")
+		check("median CPU time", s.CPU, stats.MedianInt64(s.CPU...), t.MaxMedianCPU, "max_median_cpu")
+		check("median optimizer time", s.Optimizer, stats.MedianInt64(s.Optimizer...), t.MaxMedianOptimizer, "max_median_optimizer")
+
+		base := baseGroups[bench.Name]
+		if t.MaxRegressionPct <= 0 || base == nil {
+			continue
+		}
+		baseLatency := base.Elapsed
+		if len(s.Elapsed) == 0 {
+			baseLatency = base.Client
+		}
+		for _, m := range []struct {
+			what     string
+			old, cur []int64
+		}{
+			{"median " + what, baseLatency, latency},
+			{"median CPU time", base.CPU, s.CPU},
+		} {
+			old := stats.MedianInt64(m.old...)
+			if old == 0 {
+				continue
+			}
+			delta := 100 * float64(stats.MedianInt64(m.cur...)-old) / float64(old)
+			_, p := stats.MannWhitneyU(m.old, m.cur)
+			if delta > t.MaxRegressionPct && p < regressionAlpha {
+				breaches = append(breaches, fmt.Sprintf("%v: %v regressed %+.2f%% (p=%.3f) > max_regression_pct %v%%",
+					bench.Name, m.what, delta, p, t.MaxRegressionPct))
+			}
+		}
+	}
+	return breaches
+}
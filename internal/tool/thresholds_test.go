@@ -0,0 +1,146 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ms returns the durations in milliseconds as nanoseconds.
+func ms(x ...int) []int64 {
+	ns := make([]int64, len(x))
+	for i, v := range x {
+		ns[i] = int64(v) * int64(time.Millisecond)
+	}
+	return ns
+}
+
+func TestCheckThresholds(t *testing.T) {
+	limits := &Thresholds{
+		MaxMedian:    Duration(5 * time.Millisecond),
+		MaxP99:       Duration(10 * time.Millisecond),
+		MaxMedianCPU: Duration(time.Millisecond),
+	}
+	regression := &Thresholds{MaxRegressionPct: 10}
+	fast := ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	slow := ms(11, 12, 13, 14, 15, 16, 17, 18, 19, 20)
+
+	tests := []struct {
+		name      string
+		bench     Benchmark
+		results   []benchmarkExport
+		baseline  []benchmarkExport
+		breaching []string
+	}{
+		{
+			name:    "no thresholds",
+			bench:   Benchmark{Name: "q"},
+			results: []benchmarkExport{{Name: "q", Elapsed: slow, Client: slow}},
+		},
+		{
+			name:    "within limits",
+			bench:   Benchmark{Name: "q", Thresholds: limits},
+			results: []benchmarkExport{{Name: "q", Elapsed: ms(4, 5, 6), CPU: ms(1), Client: ms(4, 5, 6)}},
+		},
+		{
+			name:  "breached",
+			bench: Benchmark{Name: "q", Thresholds: limits},
+			results: []benchmarkExport{
+				{Name: "q", Elapsed: ms(4, 6), CPU: ms(2, 2), Client: ms(4, 6)},
+				{Name: "q", Elapsed: ms(7, 11), CPU: ms(2, 2), Client: ms(7, 11)},
+			},
+			breaching: []string{
+				"q: median latency 6ms > max_median 5ms",
+				"q: p99 latency 11ms > max_p99 10ms",
+				"q: median CPU time 2ms > max_median_cpu 1ms",
+			},
+		},
+		{
+			name:      "failed",
+			bench:     Benchmark{Name: "q"},
+			results:   []benchmarkExport{{Name: "q", Failure: "failed too many times"}},
+			breaching: []string{"q: failed: failed too many times"},
+		},
+		{
+			name:      "failed with thresholds",
+			bench:     Benchmark{Name: "q", Thresholds: limits},
+			results:   []benchmarkExport{{Name: "q", Failure: "failed too many times"}},
+			breaching: []string{"q: failed: failed too many times"},
+		},
+		{
+			name:      "no samples",
+			bench:     Benchmark{Name: "q", Thresholds: limits},
+			results:   []benchmarkExport{{Name: "q"}},
+			breaching: []string{"q: no samples to check thresholds against"},
+		},
+		{
+			name:      "no results",
+			bench:     Benchmark{Name: "q", Thresholds: limits},
+			breaching: []string{"q: no samples to check thresholds against"},
+		},
+		{
+			name:    "client-side within limits",
+			bench:   Benchmark{Name: "q", Thresholds: &Thresholds{MaxMedian: limits.MaxMedian}},
+			results: []benchmarkExport{{Name: "q", Client: ms(4, 5, 6)}},
+		},
+		{
+			name:    "client-side breached",
+			bench:   Benchmark{Name: "q", Thresholds: limits},
+			results: []benchmarkExport{{Name: "q", Client: ms(6, 7, 11)}},
+			breaching: []string{
+				"q: median client latency 7ms > max_median 5ms",
+				"q: p99 client latency 11ms > max_p99 10ms",
+				"q: no server-side samples to check max_median_cpu against",
+			},
+		},
+		{
+			name:      "regressed",
+			bench:     Benchmark{Name: "q", Thresholds: regression},
+			results:   []benchmarkExport{{Name: "q", Elapsed: slow, Client: slow}},
+			baseline:  []benchmarkExport{{Name: "q", Elapsed: fast, Client: fast}},
+			breaching: []string{"q: median latency regressed +200.00% (p=0.000) > max_regression_pct 10%"},
+		},
+		{
+			name:      "client-side regressed",
+			bench:     Benchmark{Name: "q", Thresholds: regression},
+			results:   []benchmarkExport{{Name: "q", Client: slow}},
+			baseline:  []benchmarkExport{{Name: "q", Client: fast}},
+			breaching: []string{"q: median client latency regressed +200.00% (p=0.000) > max_regression_pct 10%"},
+		},
+		{
+			name:     "not regressed",
+			bench:    Benchmark{Name: "q", Thresholds: regression},
+			results:  []benchmarkExport{{Name: "q", Elapsed: fast, Client: fast}},
+			baseline: []benchmarkExport{{Name: "q", Elapsed: slow, Client: slow}},
+		},
+		{
+			name:    "no baseline",
+			bench:   Benchmark{Name: "q", Thresholds: regression},
+			results: []benchmarkExport{{Name: "q", Elapsed: slow, Client: slow}},
+		},
+	}
+	for _, tt := range tests {
+		var baseline *resultsFile
+		if tt.baseline != nil {
+			baseline = &resultsFile{Benchmarks: tt.baseline}
+		}
+		got := checkThresholds([]Benchmark{tt.bench}, &resultsFile{Benchmarks: tt.results}, baseline)
+		if !reflect.DeepEqual(got, tt.breaching) {
+			t.Errorf("%v: checkThresholds() = %q; want %q", tt.name, got, tt.breaching)
+		}
+	}
+}
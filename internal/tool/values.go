@@ -0,0 +1,489 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// Value describes a typed value in the config file. It is
+// either fixed or generated fresh for each run. Exactly one
+// of Value, Uniform, Zipfian, RandomString, Pick or CSV
+// should be set.
+type Value struct {
+	// Type is a Spanner type: INT64, FLOAT64, STRING, BYTES,
+	// BOOL, TIMESTAMP, DATE or ARRAY<T> of one of them.
+	Type string `yaml:"type" json:"type"`
+
+	Value        interface{}   `yaml:"value" json:"value,omitempty"`
+	Uniform      *Range        `yaml:"uniform" json:"uniform,omitempty"`
+	Zipfian      *Zipfian      `yaml:"zipfian" json:"zipfian,omitempty"`
+	RandomString *RandomString `yaml:"random_string" json:"random_string,omitempty"`
+	Pick         []interface{} `yaml:"pick" json:"pick,omitempty"`
+	CSV          *CSVColumn    `yaml:"csv" json:"csv,omitempty"`
+
+	// Length is the number of elements generated
+	// for ARRAY types. Fixed values and picks are
+	// used as they are.
+	Length int `yaml:"length" json:"length,omitempty"`
+}
+
+// Range is an inclusive range of INT64, FLOAT64,
+// TIMESTAMP or DATE values.
+type Range struct {
+	Min interface{} `yaml:"min" json:"min"`
+	Max interface{} `yaml:"max" json:"max"`
+}
+
+// Zipfian generates INT64 values in [Min, Max] where
+// smaller values are more likely. S (> 1) and V (>= 1)
+// are the parameters of the distribution, by default
+// 1.1 and 1.
+type Zipfian struct {
+	Min int64   `yaml:"min" json:"min"`
+	Max int64   `yaml:"max" json:"max"`
+	S   float64 `yaml:"s" json:"s,omitempty"`
+	V   float64 `yaml:"v" json:"v,omitempty"`
+}
+
+// RandomString generates STRING or BYTES values of
+// Length characters from Charset, by default
+// alphanumeric characters.
+type RandomString struct {
+	Length  int    `yaml:"length" json:"length"`
+	Charset string `yaml:"charset" json:"charset,omitempty"`
+}
+
+// CSVColumn picks random values from a column of a CSV
+// file. Column is either the header name of the column
+// if Header is set, or its zero-based index.
+type CSVColumn struct {
+	File   string `yaml:"file" json:"file"`
+	Column string `yaml:"column" json:"column"`
+	Header bool   `yaml:"header" json:"header,omitempty"`
+}
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// generator returns a new value each time it is called.
+// It is not safe for concurrent use.
+type generator func() interface{}
+
+// compile returns a generator for v that uses r
+// as its source of randomness.
+func (v Value) compile(r *rand.Rand) (generator, error) {
+	typ := strings.ToUpper(strings.TrimSpace(v.Type))
+	if typ == "" {
+		return nil, errors.New("missing type")
+	}
+	if elem, ok := arrayElem(typ); ok {
+		return v.compileArray(r, elem)
+	}
+
+	switch {
+	case v.Value != nil:
+		x, err := convert(typ, v.Value)
+		if err != nil {
+			return nil, err
+		}
+		return func() interface{} { return x }, nil
+	case v.Pick != nil:
+		return pick(r, typ, v.Pick)
+	case v.CSV != nil:
+		values, err := v.CSV.load()
+		if err != nil {
+			return nil, err
+		}
+		return pick(r, typ, values)
+	}
+	return v.compileRandom(r, typ)
+}
+
+// compileRandom compiles generators that
+// produce a single random element of typ.
+func (v Value) compileRandom(r *rand.Rand, typ string) (generator, error) {
+	switch {
+	case v.Uniform != nil:
+		return uniform(r, typ, v.Uniform)
+	case v.Zipfian != nil:
+		if typ != "INT64" {
+			return nil, fmt.Errorf("zipfian generates INT64 values, not %v", typ)
+		}
+		return zipfian(r, v.Zipfian)
+	case v.RandomString != nil:
+		return randomString(r, typ, v.RandomString)
+	}
+	return nil, errors.New("no value or generator is set")
+}
+
+func (v Value) compileArray(r *rand.Rand, elem string) (generator, error) {
+	// Fixed and picked arrays are used as they are.
+	switch {
+	case v.Value != nil:
+		x, err := convertArray(elem, v.Value)
+		if err != nil {
+			return nil, err
+		}
+		return func() interface{} { return x }, nil
+	case v.Pick != nil:
+		arrays := make([]interface{}, len(v.Pick))
+		for i, p := range v.Pick {
+			x, err := convertArray(elem, p)
+			if err != nil {
+				return nil, err
+			}
+			arrays[i] = x
+		}
+		return func() interface{} {
+			return arrays[r.Intn(len(arrays))]
+		}, nil
+	}
+
+	// Other generators produce Length elements.
+	if v.Length < 0 {
+		return nil, errors.New("array length is negative")
+	}
+	var gen generator
+	var err error
+	if v.CSV != nil {
+		var values []interface{}
+		if values, err = v.CSV.load(); err != nil {
+			return nil, err
+		}
+		gen, err = pick(r, elem, values)
+	} else {
+		gen, err = v.compileRandom(r, elem)
+	}
+	if err != nil {
+		return nil, err
+	}
+	length := v.Length
+	return func() interface{} {
+		elems := make([]interface{}, length)
+		for i := range elems {
+			elems[i] = gen()
+		}
+		return typedArray(elem, elems)
+	}, nil
+}
+
+// arrayElem returns the element type if typ is ARRAY<T>.
+func arrayElem(typ string) (string, bool) {
+	if !strings.HasPrefix(typ, "ARRAY<") || !strings.HasSuffix(typ, ">") {
+		return "", false
+	}
+	return strings.TrimSpace(typ[len("ARRAY<") : len(typ)-1]), true
+}
+
+func pick(r *rand.Rand, typ string, values []interface{}) (generator, error) {
+	if len(values) == 0 {
+		return nil, errors.New("nothing to pick from")
+	}
+	converted := make([]interface{}, len(values))
+	for i, v := range values {
+		x, err := convert(typ, v)
+		if err != nil {
+			return nil, err
+		}
+		converted[i] = x
+	}
+	return func() interface{} {
+		return converted[r.Intn(len(converted))]
+	}, nil
+}
+
+func uniform(r *rand.Rand, typ string, rng *Range) (generator, error) {
+	min, err := convert(typ, rng.Min)
+	if err != nil {
+		return nil, fmt.Errorf("uniform min: %v", err)
+	}
+	max, err := convert(typ, rng.Max)
+	if err != nil {
+		return nil, fmt.Errorf("uniform max: %v", err)
+	}
+	switch typ {
+	case "INT64":
+		lo, hi := min.(int64), max.(int64)
+		if hi < lo {
+			return nil, errors.New("uniform max is less than min")
+		}
+		// Int63n can't draw from ranges of 2^63 values or more.
+		if uint64(hi-lo) >= math.MaxInt64 {
+			return nil, errors.New("uniform range is too wide")
+		}
+		return func() interface{} {
+			return lo + r.Int63n(hi-lo+1)
+		}, nil
+	case "FLOAT64":
+		lo, hi := min.(float64), max.(float64)
+		if !(lo <= hi) {
+			return nil, errors.New("uniform max is less than min")
+		}
+		return func() interface{} {
+			return lo + r.Float64()*(hi-lo)
+		}, nil
+	case "TIMESTAMP":
+		lo, hi := min.(time.Time), max.(time.Time)
+		span := hi.Sub(lo)
+		if span < 0 {
+			return nil, errors.New("uniform max is before min")
+		}
+		if span == math.MaxInt64 {
+			// Sub saturates, the range may be wider.
+			return nil, errors.New("uniform range is too wide")
+		}
+		return func() interface{} {
+			return lo.Add(time.Duration(r.Int63n(int64(span) + 1)))
+		}, nil
+	case "DATE":
+		lo, hi := min.(civil.Date), max.(civil.Date)
+		days := hi.DaysSince(lo)
+		if days < 0 {
+			return nil, errors.New("uniform max is before min")
+		}
+		return func() interface{} {
+			return lo.AddDays(r.Intn(days + 1))
+		}, nil
+	}
+	return nil, fmt.Errorf("uniform doesn't support %v", typ)
+}
+
+func zipfian(r *rand.Rand, z *Zipfian) (generator, error) {
+	s, v := z.S, z.V
+	if s == 0 {
+		s = 1.1
+	}
+	if v == 0 {
+		v = 1
+	}
+	if s <= 1 || v < 1 {
+		return nil, errors.New("zipfian requires s > 1 and v >= 1")
+	}
+	if z.Max < z.Min {
+		return nil, errors.New("zipfian max is less than min")
+	}
+	zipf := rand.NewZipf(r, s, v, uint64(z.Max-z.Min))
+	return func() interface{} {
+		return z.Min + int64(zipf.Uint64())
+	}, nil
+}
+
+func randomString(r *rand.Rand, typ string, rs *RandomString) (generator, error) {
+	if typ != "STRING" && typ != "BYTES" {
+		return nil, fmt.Errorf("random_string generates STRING or BYTES values, not %v", typ)
+	}
+	if rs.Length < 0 {
+		return nil, errors.New("random_string length is negative")
+	}
+	charset := rs.Charset
+	if charset == "" {
+		charset = alphanumeric
+	}
+	chars := []rune(charset)
+	return func() interface{} {
+		buf := make([]rune, rs.Length)
+		for i := range buf {
+			buf[i] = chars[r.Intn(len(chars))]
+		}
+		if typ == "BYTES" {
+			return []byte(string(buf))
+		}
+		return string(buf)
+	}, nil
+}
+
+// load reads the values of the column.
+func (c *CSVColumn) load() ([]interface{}, error) {
+	f, err := os.Open(c.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %v: %v", c.File, err)
+	}
+	index := -1
+	if c.Header {
+		if len(records) == 0 {
+			return nil, fmt.Errorf("%v has no header", c.File)
+		}
+		for i, name := range records[0] {
+			if name == c.Column {
+				index = i
+			}
+		}
+		records = records[1:]
+	} else if i, err := strconv.Atoi(c.Column); err == nil {
+		index = i
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("column %q not found in %v", c.Column, c.File)
+	}
+
+	var values []interface{}
+	for _, record := range records {
+		if index >= len(record) {
+			return nil, fmt.Errorf("column %q is missing in a row of %v", c.Column, c.File)
+		}
+		values = append(values, record[index])
+	}
+	return values, nil
+}
+
+// convert converts a value decoded from YAML or CSV
+// to the Go type Spanner uses for typ.
+func convert(typ string, v interface{}) (interface{}, error) {
+	switch typ {
+	case "INT64":
+		switch x := v.(type) {
+		case int:
+			return int64(x), nil
+		case int64:
+			return x, nil
+		case uint64:
+			return int64(x), nil
+		case float64:
+			if x == float64(int64(x)) {
+				return int64(x), nil
+			}
+		case string:
+			return strconv.ParseInt(x, 10, 64)
+		}
+	case "FLOAT64":
+		switch x := v.(type) {
+		case int:
+			return float64(x), nil
+		case int64:
+			return float64(x), nil
+		case float64:
+			return x, nil
+		case string:
+			return strconv.ParseFloat(x, 64)
+		}
+	case "STRING":
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case int, int64, uint64, float64, bool:
+			return fmt.Sprint(x), nil
+		}
+	case "BYTES":
+		if x, ok := v.(string); ok {
+			return []byte(x), nil
+		}
+	case "BOOL":
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case string:
+			return strconv.ParseBool(x)
+		}
+	case "TIMESTAMP":
+		switch x := v.(type) {
+		case time.Time:
+			return x, nil
+		case string:
+			return time.Parse(time.RFC3339Nano, x)
+		}
+	case "DATE":
+		switch x := v.(type) {
+		case time.Time:
+			return civil.DateOf(x), nil
+		case string:
+			return civil.ParseDate(x)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %v", typ)
+	}
+	return nil, fmt.Errorf("cannot use %v (%T) as %v", v, v, typ)
+}
+
+func convertArray(elem string, v interface{}) (interface{}, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot use %v (%T) as ARRAY<%v>", v, v, elem)
+	}
+	elems := make([]interface{}, len(list))
+	for i, x := range list {
+		converted, err := convert(elem, x)
+		if err != nil {
+			return nil, err
+		}
+		elems[i] = converted
+	}
+	return typedArray(elem, elems), nil
+}
+
+// typedArray converts converted elements of type elem
+// to a slice type Spanner accepts as an ARRAY<elem>.
+func typedArray(elem string, elems []interface{}) interface{} {
+	switch elem {
+	case "INT64":
+		a := make([]int64, len(elems))
+		for i, e := range elems {
+			a[i] = e.(int64)
+		}
+		return a
+	case "FLOAT64":
+		a := make([]float64, len(elems))
+		for i, e := range elems {
+			a[i] = e.(float64)
+		}
+		return a
+	case "STRING":
+		a := make([]string, len(elems))
+		for i, e := range elems {
+			a[i] = e.(string)
+		}
+		return a
+	case "BYTES":
+		a := make([][]byte, len(elems))
+		for i, e := range elems {
+			a[i] = e.([]byte)
+		}
+		return a
+	case "BOOL":
+		a := make([]bool, len(elems))
+		for i, e := range elems {
+			a[i] = e.(bool)
+		}
+		return a
+	case "TIMESTAMP":
+		a := make([]time.Time, len(elems))
+		for i, e := range elems {
+			a[i] = e.(time.Time)
+		}
+		return a
+	case "DATE":
+		a := make([]civil.Date, len(elems))
+		for i, e := range elems {
+			a[i] = e.(civil.Date)
+		}
+		return a
+	}
+	return elems
+}
@@ -0,0 +1,299 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+func TestConvert(t *testing.T) {
+	ts := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	date := civil.Date{Year: 2020, Month: 6, Day: 1}
+	tests := []struct {
+		typ     string
+		v       interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{typ: "INT64", v: 42, want: int64(42)},
+		{typ: "INT64", v: int64(42), want: int64(42)},
+		{typ: "INT64", v: uint64(42), want: int64(42)},
+		{typ: "INT64", v: 42.0, want: int64(42)},
+		{typ: "INT64", v: "42", want: int64(42)},
+		{typ: "INT64", v: 1.5, wantErr: true},
+		{typ: "INT64", v: "x", wantErr: true},
+		{typ: "FLOAT64", v: 2, want: 2.0},
+		{typ: "FLOAT64", v: 2.5, want: 2.5},
+		{typ: "FLOAT64", v: "2.5", want: 2.5},
+		{typ: "FLOAT64", v: true, wantErr: true},
+		{typ: "STRING", v: "gopher", want: "gopher"},
+		{typ: "STRING", v: 7, want: "7"},
+		{typ: "STRING", v: true, want: "true"},
+		{typ: "BYTES", v: "abc", want: []byte("abc")},
+		{typ: "BYTES", v: 1, wantErr: true},
+		{typ: "BOOL", v: true, want: true},
+		{typ: "BOOL", v: "false", want: false},
+		{typ: "BOOL", v: 1, wantErr: true},
+		{typ: "TIMESTAMP", v: ts, want: ts},
+		{typ: "TIMESTAMP", v: "2020-06-01T10:00:00Z", want: ts},
+		{typ: "TIMESTAMP", v: "yesterday", wantErr: true},
+		{typ: "DATE", v: ts, want: date},
+		{typ: "DATE", v: "2020-06-01", want: date},
+		{typ: "DATE", v: 20200601, wantErr: true},
+		{typ: "NUMERIC", v: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := convert(tt.typ, tt.v)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("convert(%v, %#v) error = %v; want error %v", tt.typ, tt.v, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convert(%v, %#v) = %#v; want %#v", tt.typ, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestUniform(t *testing.T) {
+	ts := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		typ     string
+		rng     Range
+		inRange func(v interface{}) bool
+		wantErr bool
+	}{
+		{
+			typ: "INT64", rng: Range{Min: 1, Max: 3},
+			inRange: func(v interface{}) bool { x := v.(int64); return 1 <= x && x <= 3 },
+		},
+		{
+			typ: "INT64", rng: Range{Min: 5, Max: 5},
+			inRange: func(v interface{}) bool { return v.(int64) == 5 },
+		},
+		{
+			typ: "FLOAT64", rng: Range{Min: 0.5, Max: 1},
+			inRange: func(v interface{}) bool { x := v.(float64); return 0.5 <= x && x <= 1 },
+		},
+		{
+			typ: "TIMESTAMP", rng: Range{Min: ts, Max: "2020-06-01T10:00:01Z"},
+			inRange: func(v interface{}) bool {
+				x := v.(time.Time)
+				return !x.Before(ts) && !x.After(ts.Add(time.Second))
+			},
+		},
+		{
+			typ: "DATE", rng: Range{Min: "2020-06-01", Max: "2020-06-03"},
+			inRange: func(v interface{}) bool {
+				d := v.(civil.Date).DaysSince(civil.Date{Year: 2020, Month: 6, Day: 1})
+				return 0 <= d && d <= 2
+			},
+		},
+		{
+			typ: "INT64", rng: Range{Min: int64(math.MinInt64), Max: int64(-2)},
+			inRange: func(v interface{}) bool { return v.(int64) <= -2 },
+		},
+		{typ: "INT64", rng: Range{Min: 3, Max: 1}, wantErr: true},
+		{typ: "INT64", rng: Range{Min: int64(math.MinInt64), Max: int64(math.MaxInt64)}, wantErr: true},
+		{typ: "INT64", rng: Range{Min: int64(-1), Max: int64(math.MaxInt64)}, wantErr: true},
+		{typ: "FLOAT64", rng: Range{Min: 1, Max: 0.5}, wantErr: true},
+		{typ: "FLOAT64", rng: Range{Min: math.NaN(), Max: 1}, wantErr: true},
+		{typ: "TIMESTAMP", rng: Range{Min: "0001-01-01T00:00:00Z", Max: "9999-12-31T23:59:59Z"}, wantErr: true},
+		{typ: "TIMESTAMP", rng: Range{Min: "2020-06-02T00:00:00Z", Max: ts}, wantErr: true},
+		{typ: "DATE", rng: Range{Min: "2020-06-02", Max: "2020-06-01"}, wantErr: true},
+		{typ: "INT64", rng: Range{Min: "one", Max: 3}, wantErr: true},
+		{typ: "STRING", rng: Range{Min: "a", Max: "z"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		gen, err := uniform(rand.New(rand.NewSource(1)), tt.typ, &tt.rng)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("uniform(%v, %+v) error = %v; want error %v", tt.typ, tt.rng, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		for i := 0; i < 100; i++ {
+			if v := gen(); !tt.inRange(v) {
+				t.Errorf("uniform(%v, %+v) generated %v out of range", tt.typ, tt.rng, v)
+				break
+			}
+		}
+	}
+}
+
+func TestUniformCoversRange(t *testing.T) {
+	gen, err := uniform(rand.New(rand.NewSource(1)), "INT64", &Range{Min: 1, Max: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int64]bool)
+	for i := 0; i < 100; i++ {
+		seen[gen().(int64)] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("uniform generated %v; want all of 1, 2 and 3", seen)
+	}
+}
+
+func TestValueCompile(t *testing.T) {
+	tests := []struct {
+		v       Value
+		wantErr bool
+	}{
+		{v: Value{Type: "STRING", RandomString: &RandomString{Length: 4}}},
+		{v: Value{Type: "ARRAY<INT64>", Length: 3, Uniform: &Range{Min: 1, Max: 3}}},
+		{v: Value{Type: "STRING", RandomString: &RandomString{Length: -1}}, wantErr: true},
+		{v: Value{Type: "ARRAY<INT64>", Length: -1, Uniform: &Range{Min: 1, Max: 3}}, wantErr: true},
+		{v: Value{Type: "ARRAY<STRING>", Length: 2, RandomString: &RandomString{Length: -1}}, wantErr: true},
+		{v: Value{Type: "STRING"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		gen, err := tt.v.compile(rand.New(rand.NewSource(1)))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("compile(%+v) error = %v; want error %v", tt.v, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr {
+			gen() // must not panic
+		}
+	}
+}
+
+func TestZipfian(t *testing.T) {
+	tests := []struct {
+		z       Zipfian
+		wantErr bool
+	}{
+		{z: Zipfian{Min: 1, Max: 100}},
+		{z: Zipfian{Min: -10, Max: 10, S: 2, V: 5}},
+		{z: Zipfian{Min: 7, Max: 7}},
+		{z: Zipfian{Min: 1, Max: 100, S: 1}, wantErr: true},
+		{z: Zipfian{Min: 1, Max: 100, V: 0.5}, wantErr: true},
+		{z: Zipfian{Min: 100, Max: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		gen, err := zipfian(rand.New(rand.NewSource(1)), &tt.z)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("zipfian(%+v) error = %v; want error %v", tt.z, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		for i := 0; i < 100; i++ {
+			if v := gen().(int64); v < tt.z.Min || v > tt.z.Max {
+				t.Errorf("zipfian(%+v) generated %v out of range", tt.z, v)
+				break
+			}
+		}
+	}
+}
+
+func TestZipfianSkew(t *testing.T) {
+	gen, err := zipfian(rand.New(rand.NewSource(1)), &Zipfian{Min: 1, Max: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var low, high int
+	for i := 0; i < 1000; i++ {
+		switch v := gen().(int64); {
+		case v <= 10:
+			low++
+		case v > 90:
+			high++
+		}
+	}
+	if low <= high {
+		t.Errorf("zipfian generated %v values <= 10 and %v > 90; want smaller values more often", low, high)
+	}
+}
+
+func TestTypedArray(t *testing.T) {
+	ts := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	date := civil.Date{Year: 2020, Month: 6, Day: 1}
+	tests := []struct {
+		elem  string
+		elems []interface{}
+		want  interface{}
+	}{
+		{"INT64", []interface{}{int64(1), int64(2)}, []int64{1, 2}},
+		{"FLOAT64", []interface{}{1.5}, []float64{1.5}},
+		{"STRING", []interface{}{"a", "b"}, []string{"a", "b"}},
+		{"BYTES", []interface{}{[]byte("a")}, [][]byte{[]byte("a")}},
+		{"BOOL", []interface{}{true, false}, []bool{true, false}},
+		{"TIMESTAMP", []interface{}{ts}, []time.Time{ts}},
+		{"DATE", []interface{}{date}, []civil.Date{date}},
+		{"INT64", []interface{}{}, []int64{}},
+		{"NUMERIC", []interface{}{"1"}, []interface{}{"1"}},
+	}
+	for _, tt := range tests {
+		if got := typedArray(tt.elem, tt.elems); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("typedArray(%v, %v) = %#v; want %#v", tt.elem, tt.elems, got, tt.want)
+		}
+	}
+}
+
+func TestCSVColumnLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spannerbench")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	users := write("users.csv", "id,email\n1,a@example.com\n2,b@example.com\n")
+	short := write("short.csv", "1,a\n2\n")
+	empty := write("empty.csv", "")
+
+	tests := []struct {
+		name    string
+		c       CSVColumn
+		want    []interface{}
+		wantErr bool
+	}{
+		{"index", CSVColumn{File: users, Column: "1"}, []interface{}{"email", "a@example.com", "b@example.com"}, false},
+		{"header", CSVColumn{File: users, Column: "email", Header: true}, []interface{}{"a@example.com", "b@example.com"}, false},
+		{"header index", CSVColumn{File: users, Column: "0", Header: true}, nil, true},
+		{"missing column", CSVColumn{File: users, Column: "name", Header: true}, nil, true},
+		{"negative index", CSVColumn{File: users, Column: "-1"}, nil, true},
+		{"short row", CSVColumn{File: short, Column: "1"}, nil, true},
+		{"no header", CSVColumn{File: empty, Column: "id", Header: true}, nil, true},
+		{"missing file", CSVColumn{File: filepath.Join(dir, "missing.csv"), Column: "0"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.c.load()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: load() error = %v; want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: load() = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerbench
+
+import (
+	"regexp"
+	"strings"
+)
+
+// matcher selects benchmarks by name. Like go test -bench,
+// the pattern is split by slashes and each part matches
+// the name at the same level of sub-benchmarks.
+type matcher []*regexp.Regexp
+
+func newMatcher(pattern string) (matcher, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	parts := strings.Split(pattern, "/")
+	m := make(matcher, len(parts))
+	for i, p := range parts {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		m[i] = re
+	}
+	return m, nil
+}
+
+// match reports whether name at the given level of
+// sub-benchmarks should run. Levels deeper than the
+// pattern always match.
+func (m matcher) match(level int, name string) bool {
+	if level >= len(m) {
+		return true
+	}
+	return m[level].MatchString(name)
+}
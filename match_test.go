@@ -0,0 +1,56 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerbench
+
+import "testing"
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		pattern string
+		level   int
+		name    string
+		want    bool
+	}{
+		{"", 0, "benchmarkReads", true},
+		{"", 1, "limit=10", true},
+		{"Reads", 0, "benchmarkReads", true},
+		{"Reads", 0, "benchmarkWrites", false},
+		{"Reads", 1, "limit=10", true},
+		{"^benchmarkReads$", 0, "benchmarkReadsAll", false},
+		{"Reads/limit=1$", 1, "limit=1", true},
+		{"Reads/limit=1$", 1, "limit=10", false},
+		{"Reads/limit=1$", 2, "anything", true},
+		{"/limit=1", 0, "benchmarkWrites", true},
+		{"/limit=1", 1, "limit=100", true},
+		{"/limit=1", 1, "offset=1", false},
+	}
+	for _, tt := range tests {
+		m, err := newMatcher(tt.pattern)
+		if err != nil {
+			t.Fatalf("newMatcher(%q) = %v", tt.pattern, err)
+		}
+		if got := m.match(tt.level, tt.name); got != tt.want {
+			t.Errorf("newMatcher(%q).match(%v, %q) = %v; want %v", tt.pattern, tt.level, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewMatcherInvalid(t *testing.T) {
+	for _, pattern := range []string{"(", "Reads/[", "a/b/*"} {
+		if _, err := newMatcher(pattern); err == nil {
+			t.Errorf("newMatcher(%q) = nil error; want an error", pattern)
+		}
+	}
+}
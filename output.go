@@ -0,0 +1,146 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerbench
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudspannerecosystem/spanner-bench/internal/benchfmt"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/histogram"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/runner"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/stats"
+)
+
+func (b *B) print(name string) {
+	w := b.output()
+	if b.format == FormatBench {
+		b.printBench(w, name)
+		return
+	}
+
+	r := b.result
+	if b.concurrency > 1 {
+		fmt.Fprintf(w, "Concurrency: %v\n", b.concurrency)
+	}
+	if b.rate > 0 {
+		fmt.Fprintf(w, "Target rate: %.2f txn/s\n", b.rate)
+	}
+	if wu := b.warmUpResult; wu != nil {
+		fmt.Fprintf(w, "Warm-up: %v iterations in %v, median %v, %v errors\n",
+			len(wu.Elapsed), wu.Duration, time.Duration(stats.MedianInt64(wu.Elapsed...)), wu.Errors)
+	}
+	fmt.Fprintf(w, "Iterations: %v in %v\n", len(r.Elapsed), r.Duration)
+	fmt.Fprintf(w, "Throughput: %.2f txn/s\n", r.Throughput())
+	if len(b.rows) > 0 {
+		fmt.Fprintf(w, "Rows affected: %.0f per iteration\n", stats.MeanInt64(b.rows...))
+	}
+	if b.stabilize > 0 {
+		fmt.Fprintf(w, "Confidence: %v\n", r.IntervalString())
+	}
+	if r.Errors > 0 {
+		fmt.Fprintf(w, "Errors: %v (%v)\n", r.Errors, r.CodesString())
+		fmt.Fprintf(w, "Last error: %v\n", r.LastErr)
+	}
+	if r.TeardownErrors > 0 {
+		fmt.Fprintf(w, "Teardown errors: %v, last: %v\n", r.TeardownErrors, r.LastTeardownErr)
+	}
+	if r.Err != nil {
+		fmt.Fprintf(w, "FAIL: %v\n", r.Err)
+	}
+	if len(r.Elapsed) > 0 {
+		fmt.Fprintln(w, "Latency summary:")
+		printSummary(w, stats.Summarize(r.Elapsed...))
+	}
+	if s := b.batch; s != nil && s.Runs > 0 {
+		fmt.Fprintf(w, "Partitions: %.1f per iteration, %v rows, %.2f rows/s\n",
+			float64(s.Partitions)/float64(s.Runs), s.Rows, float64(s.Rows)/r.Duration.Seconds())
+		fmt.Fprintln(w, "Partition latency summary:")
+		printSummary(w, stats.Summarize(s.Elapsed...))
+	}
+	if histogram := histogram.NewHistogram(r.Elapsed); histogram != nil {
+		fmt.Fprintln(w, "Latency histogram:")
+		fmt.Fprintln(w, histogram)
+	}
+}
+
+// printBench prints the result as a Go benchmark line.
+// Runs without any successful iterations are skipped.
+func (b *B) printBench(w io.Writer, name string) {
+	r := b.result
+	if len(r.Elapsed) == 0 {
+		return
+	}
+	s := stats.Summarize(r.Elapsed...)
+	metrics := []benchfmt.Metric{
+		{Value: s.Mean, Unit: "ns/op"},
+		{Value: float64(s.P50), Unit: "p50-ns/op"},
+		{Value: float64(s.P99), Unit: "p99-ns/op"},
+		{Value: r.Throughput(), Unit: "txn/s"},
+	}
+	if len(b.rows) > 0 {
+		metrics = append(metrics, benchfmt.Metric{Value: stats.MeanInt64(b.rows...), Unit: "rows/op"})
+	}
+	if s := b.batch; s != nil && s.Runs > 0 {
+		metrics = append(metrics,
+			benchfmt.Metric{Value: float64(s.Partitions) / float64(s.Runs), Unit: "partitions/op"},
+			benchfmt.Metric{Value: stats.MeanInt64(s.Elapsed...), Unit: "partition-ns/op"},
+			benchfmt.Metric{Value: float64(s.Rows) / r.Duration.Seconds(), Unit: "rows/s"},
+		)
+	}
+	benchfmt.Write(w, name, len(r.Elapsed), metrics...)
+}
+
+func printSummary(w io.Writer, s stats.Summary) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  min\tp50\tp90\tp95\tp99\tp99.9\tmax\tmean\tstddev\t")
+	fmt.Fprintf(tw, "  %v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t\n",
+		time.Duration(s.Min), time.Duration(s.P50), time.Duration(s.P90),
+		time.Duration(s.P95), time.Duration(s.P99), time.Duration(s.P999),
+		time.Duration(s.Max), time.Duration(s.Mean), time.Duration(s.StdDev))
+	tw.Flush()
+}
+
+// printBounds prints the results of a benchmark
+// run with different timestamp bounds side by side.
+func printBounds(w io.Writer, results []Result) {
+	fmt.Fprintln(w, "Timestamp bounds:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  \titerations\tp50\tp99\tmean\ttxn/s\terrors\t")
+	for _, r := range results {
+		s := r.Summary()
+		fmt.Fprintf(tw, "  %v\t%v\t%v\t%v\t%v\t%.2f\t%v\t\n", r.Name,
+			len(r.Elapsed), s.P50, s.P99, s.Mean, r.Throughput, r.Errors)
+	}
+	tw.Flush()
+}
+
+func printFailures(w io.Writer, results []Result) {
+	var failed []Result
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%v of %v benchmarks failed:\n", len(failed), len(results))
+	for _, r := range failed {
+		fmt.Fprintf(w, "  %v: %v (%v)\n", r.Name, r.Err, runner.FormatCodes(r.ErrorCodes))
+	}
+}
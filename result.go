@@ -0,0 +1,238 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerbench
+
+import (
+	"time"
+
+	"github.com/cloudspannerecosystem/spanner-bench/internal/batchread"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/histogram"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/runner"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/stats"
+	"google.golang.org/grpc/codes"
+)
+
+// Result is the result of a benchmark.
+type Result struct {
+	// Name is the name of the benchmark function without
+	// its package, followed by the names of sub-benchmarks
+	// or timestamp bounds separated by slashes, e.g.
+	// "benchmarkReads/limit=10" or
+	// "benchmarkReads/maxStaleness=15s".
+	Name string
+
+	// Elapsed is the latency of each successful iteration.
+	Elapsed []time.Duration
+
+	// Errors is the number of failed iterations.
+	Errors int
+
+	// ErrorCodes counts failed iterations by their
+	// gRPC status code.
+	ErrorCodes map[codes.Code]int
+
+	// LastError is the error returned by the last
+	// failed iteration.
+	LastError error
+
+	// TeardownErrors is the number of TeardownEach calls
+	// that failed and LastTeardownError is the error of
+	// the last one. They don't fail iterations.
+	TeardownErrors    int
+	LastTeardownError error
+
+	// Err is non-nil if the benchmark was abandoned
+	// because it exceeded its failure budget.
+	Err error
+
+	// WarmUp is the latency of each successful warm-up
+	// iteration. Warm-up iterations are not included
+	// in the other fields.
+	WarmUp []time.Duration
+
+	// Duration is the wall time spent running
+	// the measured iterations.
+	Duration time.Duration
+
+	// Throughput is the number of successful
+	// transactions per second.
+	Throughput float64
+
+	// Interval is the confidence interval of the
+	// latency percentile used to stabilize results.
+	Interval Interval
+
+	// RowsAffected is the number of rows affected by
+	// each Partitioned DML iteration, in no particular
+	// order. It is empty for other benchmarks.
+	RowsAffected []int64
+
+	// Partitions describes the partitions read by batch
+	// read-only benchmarks. It is nil for other benchmarks.
+	Partitions *Partitions
+
+	// Histogram is the latency histogram. It is empty
+	// if there are not enough samples to build one.
+	Histogram []Bucket
+}
+
+// Partitions describes the partitions read by
+// a batch read-only benchmark.
+type Partitions struct {
+	// Count is the number of partitions read
+	// in all iterations.
+	Count int
+
+	// Elapsed is the latency of reading each partition.
+	Elapsed []time.Duration
+
+	// Rows is the number of rows read in all iterations
+	// and RowsPerSecond is the rate they were read at.
+	Rows          int64
+	RowsPerSecond float64
+}
+
+// Interval is a confidence interval of a latency percentile.
+type Interval struct {
+	// Percentile is in [0, 100] and Confidence is
+	// the confidence level, e.g. 0.95.
+	Percentile float64
+	Confidence float64
+
+	// Estimate is the percentile of the latencies
+	// and [Low, High] is its confidence interval.
+	Estimate time.Duration
+	Low      time.Duration
+	High     time.Duration
+
+	// Stable is true if the interval was narrow
+	// enough to stop a stabilized benchmark.
+	Stable bool
+}
+
+// Summary summarizes the latencies of a benchmark.
+type Summary struct {
+	Min    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	StdDev time.Duration
+	P50    time.Duration
+	P90    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+	P999   time.Duration
+}
+
+// Bucket is a bucket in a latency histogram.
+type Bucket struct {
+	// Mark is the upper bound of the bucket.
+	Mark time.Duration
+
+	// Count is the number of samples in the bucket.
+	Count int
+
+	// Frequency is the ratio of samples in the bucket
+	// to the total number of samples.
+	Frequency float64
+}
+
+// Percentile returns the pth percentile of the latencies,
+// where p is in [0, 100]. It returns zero if there are
+// no samples.
+func (r *Result) Percentile(p float64) time.Duration {
+	return time.Duration(stats.PercentileInt64(p, r.nanos()...))
+}
+
+// Summary returns the percentiles, min, max, mean
+// and standard deviation of the latencies.
+func (r *Result) Summary() Summary {
+	s := stats.Summarize(r.nanos()...)
+	return Summary{
+		Min:    time.Duration(s.Min),
+		Max:    time.Duration(s.Max),
+		Mean:   time.Duration(s.Mean),
+		StdDev: time.Duration(s.StdDev),
+		P50:    time.Duration(s.P50),
+		P90:    time.Duration(s.P90),
+		P95:    time.Duration(s.P95),
+		P99:    time.Duration(s.P99),
+		P999:   time.Duration(s.P999),
+	}
+}
+
+func (r *Result) nanos() []int64 {
+	x := make([]int64, len(r.Elapsed))
+	for i, d := range r.Elapsed {
+		x[i] = int64(d)
+	}
+	return x
+}
+
+func newResult(name string, warmUp, result *runner.Result) Result {
+	r := Result{Name: name}
+	if result == nil {
+		return r // benchmark didn't run
+	}
+	r.Elapsed = durations(result.Elapsed)
+	if warmUp != nil {
+		r.WarmUp = durations(warmUp.Elapsed)
+	}
+	r.Errors = result.Errors
+	r.ErrorCodes = result.Codes
+	r.LastError = result.LastErr
+	r.TeardownErrors = result.TeardownErrors
+	r.LastTeardownError = result.LastTeardownErr
+	r.Err = result.Err
+	r.Duration = result.Duration
+	r.Interval = Interval{
+		Percentile: result.Percentile,
+		Confidence: result.Confidence,
+		Estimate:   time.Duration(result.Estimate),
+		Low:        time.Duration(result.CILow),
+		High:       time.Duration(result.CIHigh),
+		Stable:     result.Stable,
+	}
+	r.Throughput = result.Throughput()
+	if h := histogram.NewHistogram(result.Elapsed); h != nil {
+		for _, b := range h.Buckets() {
+			r.Histogram = append(r.Histogram, Bucket{
+				Mark:      time.Duration(b.Mark),
+				Count:     b.Count,
+				Frequency: b.Frequency,
+			})
+		}
+	}
+	return r
+}
+
+func newPartitions(s *batchread.Stats, d time.Duration) *Partitions {
+	p := &Partitions{
+		Count:   s.Partitions,
+		Elapsed: durations(s.Elapsed),
+		Rows:    s.Rows,
+	}
+	if d > 0 {
+		p.RowsPerSecond = float64(s.Rows) / d.Seconds()
+	}
+	return p
+}
+
+func durations(x []int64) []time.Duration {
+	d := make([]time.Duration, len(x))
+	for i, v := range x {
+		d[i] = time.Duration(v)
+	}
+	return d
+}
@@ -18,40 +18,124 @@ package spannerbench
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/spanner"
-	"github.com/cloudspannerecosystem/spanner-bench/internal/histogram"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/batchread"
+	"github.com/cloudspannerecosystem/spanner-bench/internal/runner"
 	"google.golang.org/api/option"
 )
 
 // B represents a benchmark.
 // Use Benchmark function to run benchmarks.
 type B struct {
-	client    *spanner.Client
-	staleness *spanner.TimestampBound
-	n         int
+	ctx         context.Context
+	name        string
+	client      *spanner.Client
+	staleness   *spanner.TimestampBound
+	bounded     bool // staleness is a bounded staleness
+	bounds      []spanner.TimestampBound
+	n           int
+	duration    time.Duration
+	stabilize   float64
+	maxN        int
+	percentile  float64
+	concurrency int
+	parallelism int
+	rate        float64
+	maxFailures *int
+	timeout     time.Duration
 
-	elapsed []int64
+	warmUp         int
+	warmUpDuration time.Duration
+	sessions       int
+
+	setup, teardown         func(ctx context.Context, client *spanner.Client) error
+	setupEach, teardownEach func(ctx context.Context, client *spanner.Client) error
+
+	out        io.Writer
+	format     Format
+	disposable bool
+	match      matcher
+	level      int  // of sub-benchmarks
+	parent     *B   // nil unless b is a sub-benchmark
+	named      bool // whether the name was printed
+	filtered   bool // whether a sub-benchmark was skipped
+
+	warmUpResult *runner.Result
+	result       *runner.Result
+	rows         []int64 // rows affected by each iteration, if any
+	batch        *batchread.Stats
+	results      []Result
+}
+
+// Strong makes reads see the effects of all transactions
+// committed before the read starts. Reads are strong if no
+// other timestamp bound is set. It will be ignored for
+// read-write transactions.
+func (b *B) Strong() {
+	tb := spanner.StrongRead()
+	b.staleness, b.bounded = &tb, false
 }
 
 // MaxStaleness sets the max staleness in reads
 // It will be ignored for read-write transactions.
+//
+// Spanner only supports bounded staleness in single-use
+// transactions, so fn can only do a single read.
 func (b *B) MaxStaleness(d time.Duration) {
 	tb := spanner.MaxStaleness(d)
-	b.staleness = &tb
+	b.staleness, b.bounded = &tb, true
 }
 
 // ExactStaleness represents the exact staleness
 // in reads. It will be ignored for read-write transactions.
 func (b *B) ExactStaleness(d time.Duration) {
 	tb := spanner.ExactStaleness(d)
-	b.staleness = &tb
+	b.staleness, b.bounded = &tb, false
+}
+
+// ReadTimestamp makes reads observe the database
+// at t. It will be ignored for read-write transactions.
+func (b *B) ReadTimestamp(t time.Time) {
+	tb := spanner.ReadTimestamp(t)
+	b.staleness, b.bounded = &tb, false
+}
+
+// MinReadTimestamp makes reads observe the database at
+// a timestamp not older than t. It will be ignored for
+// read-write transactions.
+//
+// Spanner only supports bounded staleness in single-use
+// transactions, so fn can only do a single read.
+func (b *B) MinReadTimestamp(t time.Time) {
+	tb := spanner.MinReadTimestamp(t)
+	b.staleness, b.bounded = &tb, true
+}
+
+// TimestampBounds makes RunReadOnly and RunSingle run the benchmark
+// once for each of the bounds, e.g. to compare strong
+// and stale reads side by side. Each run is reported as
+// a result named after the benchmark and the bound.
+// It overrides the other timestamp bound options.
+//
+// Spanner only supports bounded staleness (max staleness
+// and min read timestamp bounds) in single-use transactions,
+// so like MaxStaleness and MinReadTimestamp, these bounds
+// switch RunReadOnly to single-use transactions and fn can
+// only do a single read with them.
+func (b *B) TimestampBounds(bounds ...spanner.TimestampBound) {
+	b.bounds = bounds
 }
 
 // N sets the number of times a benchmarks will be run.
@@ -60,97 +144,495 @@ func (b *B) N(n int) {
 	b.n = n
 }
 
-// TODO(jbd): Allow users to set concurrency.
+// Duration makes the benchmark run until d is spent
+// instead of running a fixed number of times.
+// N is ignored if a duration is set.
+func (b *B) Duration(d time.Duration) {
+	b.duration = d
+}
+
+// Stabilize makes the benchmark keep running after min
+// iterations until the 95% confidence interval of the
+// median latency is narrower than width relative to the
+// median, or until max iterations are run. For example,
+// width 0.05 stops once the interval is 5% of the median.
+func (b *B) Stabilize(width float64, min, max int) {
+	b.stabilize = width
+	b.n = min
+	b.maxN = max
+}
+
+// StabilizePercentile sets the latency percentile,
+// in [0, 100], Stabilize looks at instead of the median.
+func (b *B) StabilizePercentile(p float64) {
+	b.percentile = p
+}
+
+// Concurrency sets the number of goroutines running
+// the benchmark in parallel. All goroutines share the
+// same Spanner client. If not set, the benchmark is
+// run sequentially.
+func (b *B) Concurrency(n int) {
+	b.concurrency = n
+}
+
+// PartitionParallelism sets the number of partitions
+// RunPartitionQuery and RunPartitionRead read in parallel
+// in each iteration. If not set, partitions are read one
+// at a time.
+func (b *B) PartitionParallelism(n int) {
+	b.parallelism = n
+}
+
+// Rate makes the benchmark start r transactions per
+// second on a fixed schedule, no matter how long previous
+// transactions take. Latencies are measured from the
+// scheduled start, so they include queueing delay when
+// Spanner can't keep up. Set Concurrency high enough
+// to sustain the rate.
+func (b *B) Rate(r float64) {
+	b.rate = r
+}
+
+// MaxFailures sets the number of failed iterations
+// tolerated before the benchmark is abandoned.
+// If not set, up to 2*N failures are tolerated.
+// Zero tolerates no failures. If negative, failures
+// never abandon the benchmark.
+func (b *B) MaxFailures(n int) {
+	b.maxFailures = &n
+}
+
+// Timeout sets the deadline of each iteration. The context
+// passed to RunContext and RunReadOnlyContext callbacks
+// carries the deadline. If not set, iterations only stop
+// when the context given to BenchmarkContext is done.
+func (b *B) Timeout(d time.Duration) {
+	b.timeout = d
+}
+
+// WarmUp sets the number of untimed iterations to run
+// before the benchmark is measured. Warm-up latencies are
+// reported separately and are not part of the results.
+func (b *B) WarmUp(n int) {
+	b.warmUp = n
+}
+
+// WarmUpDuration is like WarmUp but keeps running
+// untimed iterations for d.
+func (b *B) WarmUpDuration(d time.Duration) {
+	b.warmUpDuration = d
+}
+
+// PrefillSessions makes sure at least n sessions are
+// created in the client's session pool before the
+// benchmark starts.
+func (b *B) PrefillSessions(n int) {
+	b.sessions = n
+}
+
+// Sub runs fn as a sub-benchmark named name, e.g. to
+// benchmark variants of a query with different limits or
+// indexes. The sub-benchmark starts with the options of b
+// and is reported as a separate result named after b and
+// name, separated by a slash. Options set in fn don't
+// change b. Sub-benchmarks not selected by Options.Bench
+// are skipped.
+//
+// Sub is not safe for concurrent usage.
+func (b *B) Sub(name string, fn func(b *B)) {
+	if !b.match.match(b.level+1, name) {
+		b.filtered = true
+		return
+	}
+	sub := *b
+	sub.name = b.name + "/" + name
+	sub.level = b.level + 1
+	sub.parent = b
+	sub.named = false
+	sub.filtered = false
+	sub.warmUpResult = nil
+	sub.result = nil
+	sub.rows = nil
+	sub.batch = nil
+	sub.results = nil
+	fn(&sub)
+	b.results = append(b.results, sub.results...)
+	if sub.filtered && len(sub.results) == 0 {
+		b.filtered = true
+	}
+}
+
+// Setup sets fn to be called once before the benchmark
+// and its warm-up, e.g. to insert the rows it reads. If fn
+// fails, the benchmark fails without running.
+func (b *B) Setup(fn func(ctx context.Context, client *spanner.Client) error) {
+	b.setup = fn
+}
+
+// Teardown sets fn to be called once after the benchmark,
+// even if it fails, e.g. to delete the rows it inserts.
+func (b *B) Teardown(fn func(ctx context.Context, client *spanner.Client) error) {
+	b.teardown = fn
+}
+
+// SetupEach sets fn to be called before each iteration,
+// outside of the timed region. If fn fails, the iteration
+// fails.
+func (b *B) SetupEach(fn func(ctx context.Context, client *spanner.Client) error) {
+	b.setupEach = fn
+}
+
+// TeardownEach sets fn to be called after each iteration,
+// even if it fails, outside of the timed region. Errors of
+// fn are counted in Result.TeardownErrors and don't fail
+// the iteration, which was already measured.
+func (b *B) TeardownEach(fn func(ctx context.Context, client *spanner.Client) error) {
+	b.teardownEach = fn
+}
 
 // RunReadOnly runs readonly transaction benchmarks.
 // It starts a read-only transaction and calls fn.
 // The benchmark will be repeated for a number of times
 // and results will be printed.
 //
+// If concurrency is set, fn is called from multiple
+// goroutines and should be safe for concurrent use.
+//
 // Run is not safe for concurrent usage. Don't reuse this
 // benchmark once you call RunReadOnly.
 func (b *B) RunReadOnly(fn func(tx *spanner.ReadOnlyTransaction) error) {
-	// TODO(jbd): Cleanup after running.
-	var i, retries int
+	b.RunReadOnlyContext(func(ctx context.Context, tx *spanner.ReadOnlyTransaction) error {
+		return fn(tx)
+	})
+}
 
-	n := b.numberOfRuns()
-	for {
-		if i == n {
-			break
-		}
-		err := b.startAndRunReadOnly(fn)
-		retries++
-		if err != nil {
-			if retries > 2*n {
-				log.Fatalf("Query failed too many times: %v\n", err)
-			}
-			continue
+// RunReadOnlyContext is like RunReadOnly but passes fn
+// the context of the iteration. Use it in all Spanner
+// calls to respect cancellation and deadlines.
+func (b *B) RunReadOnlyContext(fn func(ctx context.Context, tx *spanner.ReadOnlyTransaction) error) {
+	b.runReadOnlyBounds(false, fn)
+}
+
+// RunSingle runs single-use read-only transaction
+// benchmarks. It starts a single-use transaction with
+// client.Single and calls fn, which can only do a single
+// read or query. Single-use transactions save the round
+// trip to begin a transaction, like point lookups often do.
+//
+// RunSingle is not safe for concurrent usage. Don't reuse
+// this benchmark once you call RunSingle.
+func (b *B) RunSingle(fn func(tx *spanner.ReadOnlyTransaction) error) {
+	b.RunSingleContext(func(ctx context.Context, tx *spanner.ReadOnlyTransaction) error {
+		return fn(tx)
+	})
+}
+
+// RunSingleContext is like RunSingle but passes fn the
+// context of the iteration.
+func (b *B) RunSingleContext(fn func(ctx context.Context, tx *spanner.ReadOnlyTransaction) error) {
+	b.runReadOnlyBounds(true, fn)
+}
+
+// runReadOnlyBounds runs a read-only benchmark with
+// each of the timestamp bounds, if any are set.
+func (b *B) runReadOnlyBounds(single bool, fn func(ctx context.Context, tx *spanner.ReadOnlyTransaction) error) {
+	if len(b.bounds) == 0 {
+		b.runReadOnly(b.name, b.staleness, single || b.bounded, fn)
+		return
+	}
+	b.printName()
+	for i := range b.bounds {
+		tb := b.bounds[i]
+		name := b.name + "/" + boundName(tb)
+		if b.format != FormatBench {
+			fmt.Fprintln(b.output(), name)
 		}
-		i++
+		b.runReadOnly(name, &tb, single || isBounded(tb), fn)
+	}
+	if b.format != FormatBench {
+		printBounds(b.output(), b.results[len(b.results)-len(b.bounds):])
 	}
-	b.print()
 }
 
-func (b *B) startAndRunReadOnly(fn func(tx *spanner.ReadOnlyTransaction) error) error {
-	start := time.Now()
-	defer func() {
-		dur := time.Now().Sub(start)
-		b.elapsed = append(b.elapsed, int64(dur))
-	}()
+func (b *B) runReadOnly(name string, tb *spanner.TimestampBound, single bool, fn func(ctx context.Context, tx *spanner.ReadOnlyTransaction) error) {
+	b.runN(func(ctx context.Context) error {
+		return b.startAndRunReadOnly(ctx, tb, single, fn)
+	})
+	b.record(name)
+}
 
-	// TODO(jbd): Add strong read as an option.
-	tx := b.client.ReadOnlyTransaction()
-	if b.staleness != nil {
-		tx = tx.WithTimestampBound(*b.staleness)
+func (b *B) startAndRunReadOnly(ctx context.Context, tb *spanner.TimestampBound, single bool, fn func(ctx context.Context, tx *spanner.ReadOnlyTransaction) error) error {
+	var tx *spanner.ReadOnlyTransaction
+	if single {
+		tx = b.client.Single()
+	} else {
+		tx = b.client.ReadOnlyTransaction()
+	}
+	if tb != nil {
+		tx = tx.WithTimestampBound(*tb)
 	}
 	defer tx.Close()
 
-	return fn(tx)
+	return fn(ctx, tx)
 }
 
+// isBounded reports whether tb is a bounded staleness,
+// which can only be used in single-use transactions.
+func isBounded(tb spanner.TimestampBound) bool {
+	// The mode of the bound is not exported.
+	s := tb.String()
+	return strings.HasPrefix(s, "(maxStaleness:") || strings.HasPrefix(s, "(minReadTimestamp:")
+}
+
+// boundName returns a short name for tb, e.g.
+// "exactStaleness=15s" or, with timestamps in RFC 3339,
+// "readTimestamp=2020-06-01T10:00:00Z".
+func boundName(tb spanner.TimestampBound) string {
+	// The mode of the bound is not exported.
+	s := strings.Trim(tb.String(), "()")
+	i := strings.Index(s, ": ")
+	if i < 0 {
+		return s
+	}
+	kind, v := s[:i], s[i+len(": "):]
+	if j := strings.Index(v, " m="); j >= 0 {
+		v = v[:j] // monotonic clock reading
+	}
+	if t, err := time.Parse(timeStringLayout, v); err == nil {
+		v = t.Format(time.RFC3339Nano)
+	}
+	return kind + "=" + v
+}
+
+// timeStringLayout is the layout of time.Time.String.
+const timeStringLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
 // Run runs read-write transaction benchmarks.
 // It starts a read-write transaction and calls fn.
 // The benchmark will be repeated for a number of times
 // and results will be printed.
 //
+// If concurrency is set, fn is called from multiple
+// goroutines and should be safe for concurrent use.
+//
 // Run is not safe for concurrent usage. Don't reuse this
 // benchmark once you call Run.
 func (b *B) Run(fn func(tx *spanner.ReadWriteTransaction) error) {
-	// TODO(jbd): Remove duplication by merging Run and RunReadOnly.
-	var i, retries int
+	b.RunContext(func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		return fn(tx)
+	})
+}
 
-	n := b.numberOfRuns()
-	for {
-		if i == n {
-			break
-		}
-		err := b.startAndRun(fn)
-		retries++
+// RunContext is like Run but passes fn the context
+// of the transaction. Use it in all Spanner calls
+// to respect cancellation and deadlines.
+func (b *B) RunContext(fn func(ctx context.Context, tx *spanner.ReadWriteTransaction) error) {
+	b.runN(func(ctx context.Context) error {
+		return b.startAndRun(ctx, fn)
+	})
+	b.record(b.name)
+}
+
+// RunApply runs blind write benchmarks. It calls fn for
+// the mutations of each iteration and writes them with
+// client.Apply, so the latency includes the commit.
+// fn is part of the timed iteration and should be cheap.
+//
+// To benchmark mutations buffered in a read-write
+// transaction, call tx.BufferWrite from Run instead.
+//
+// If concurrency is set, fn is called from multiple
+// goroutines and should be safe for concurrent use.
+//
+// RunApply is not safe for concurrent usage. Don't reuse
+// this benchmark once you call RunApply.
+func (b *B) RunApply(fn func() []*spanner.Mutation, opts ...spanner.ApplyOption) {
+	b.runN(func(ctx context.Context) error {
+		_, err := b.client.Apply(ctx, fn(), opts...)
+		return err
+	})
+	b.record(b.name)
+}
+
+// RunPartitionedUpdate runs Partitioned DML benchmarks.
+// Each iteration runs stmt with client.PartitionedUpdate
+// and records its duration and the number of affected rows.
+//
+// Partitioned DML usually modifies a large part of a table,
+// so the benchmark fails without running unless the database
+// is marked disposable with Options.Disposable.
+//
+// RunPartitionedUpdate is not safe for concurrent usage.
+// Don't reuse this benchmark once you call it.
+func (b *B) RunPartitionedUpdate(stmt spanner.Statement) {
+	if !b.disposable {
+		b.result = &runner.Result{Err: errNotDisposable}
+		b.record(b.name)
+		return
+	}
+	var mu sync.Mutex
+	b.runN(func(ctx context.Context) error {
+		n, err := b.client.PartitionedUpdate(ctx, stmt)
 		if err != nil {
-			if retries > 2*n {
-				log.Fatalf("Query failed too many times: %v\n", err)
-			}
-			continue
+			return err
 		}
-		i++
-	}
-	b.print()
+		mu.Lock()
+		defer mu.Unlock()
+		b.rows = append(b.rows, n)
+		return nil
+	})
+	b.record(b.name)
 }
 
-func (b *B) startAndRun(fn func(tx *spanner.ReadWriteTransaction) error) error {
-	start := time.Now()
-	defer func() {
-		dur := time.Now().Sub(start)
-		b.elapsed = append(b.elapsed, int64(dur))
-	}()
+// RunPartitionQuery runs batch read-only benchmarks.
+// Each iteration starts a batch read-only transaction,
+// partitions stmt with PartitionQuery and reads all the
+// partitions, PartitionParallelism at a time. The number
+// of partitions, the latency of each partition and the
+// rows read per second are reported with the latency of
+// the iterations.
+//
+// The timestamp bound, if set, must be strong, an exact
+// staleness or a read timestamp.
+//
+// RunPartitionQuery is not safe for concurrent usage.
+// Don't reuse this benchmark once you call it.
+func (b *B) RunPartitionQuery(stmt spanner.Statement) {
+	b.runBatch(batchread.Query(stmt))
+}
 
-	ctx := context.Background() // TODO(jbd): Consider adding context to the APIs.
-	_, err := b.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
-		return fn(tx)
+// RunPartitionRead is like RunPartitionQuery but
+// partitions a read of columns in keys of table
+// with PartitionRead.
+func (b *B) RunPartitionRead(table string, keys spanner.KeySet, columns []string) {
+	b.runBatch(batchread.Read(table, "", keys, columns))
+}
+
+func (b *B) runBatch(partition batchread.Partitioner) {
+	tb := spanner.StrongRead()
+	if b.staleness != nil {
+		tb = *b.staleness
+	}
+	b.batch = &batchread.Stats{}
+	b.runN(func(ctx context.Context) error {
+		r, err := batchread.Run(ctx, b.client, tb, partition, b.parallelism)
+		if err != nil {
+			return err
+		}
+		b.batch.Add(r)
+		return nil
 	})
+	b.record(b.name)
+}
+
+var errNotDisposable = errors.New("partitioned DML requires a database marked disposable in Options")
+
+func (b *B) startAndRun(ctx context.Context, fn func(ctx context.Context, tx *spanner.ReadWriteTransaction) error) error {
+	_, err := b.client.ReadWriteTransaction(ctx, fn)
 	return err
 }
 
+func (b *B) runN(fn func(ctx context.Context) error) {
+	b.printName()
+	ctx := b.context()
+	cfg := runner.Config{
+		N:              b.numberOfRuns(),
+		Duration:       b.duration,
+		Stabilize:      b.stabilize,
+		MaxN:           b.maxN,
+		Percentile:     b.percentile,
+		Concurrency:    b.concurrency,
+		Rate:           b.rate,
+		MaxFailures:    b.maxFailures,
+		Timeout:        b.timeout,
+		WarmUp:         b.warmUp,
+		WarmUpDuration: b.warmUpDuration,
+	}
+	if b.setupEach != nil {
+		cfg.Setup = b.hook(b.setupEach)
+	}
+	if b.teardownEach != nil {
+		cfg.Teardown = b.hook(b.teardownEach)
+	}
+	if b.sessions > 0 {
+		if err := runner.PrefillSessions(ctx, b.client, b.sessions); err != nil {
+			log.Printf("Cannot prefill sessions: %v", err)
+		}
+	}
+	if b.setup != nil {
+		if err := b.setup(ctx, b.client); err != nil {
+			b.warmUpResult = nil
+			b.result = &runner.Result{Err: fmt.Errorf("setup failed: %v", err)}
+			return
+		}
+	}
+	if b.teardown != nil {
+		defer func() {
+			// Clean up even if the benchmark's context is done.
+			if err := b.teardown(context.Background(), b.client); err != nil && b.result.Err == nil {
+				b.result.Err = fmt.Errorf("teardown failed: %v", err)
+			}
+		}()
+	}
+	b.warmUpResult = runner.WarmUp(ctx, cfg, fn)
+	// Only keep what the measured iterations report.
+	b.rows = nil
+	if b.batch != nil {
+		b.batch = &batchread.Stats{}
+	}
+	b.result = runner.Run(ctx, cfg, fn)
+}
+
+func (b *B) hook(fn func(ctx context.Context, client *spanner.Client) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return fn(ctx, b.client)
+	}
+}
+
+// record prints and keeps the result of the last run.
+func (b *B) record(name string) {
+	b.printName()
+	b.print(name)
+	r := newResult(name, b.warmUpResult, b.result)
+	r.RowsAffected = b.rows
+	if b.batch != nil {
+		r.Partitions = newPartitions(b.batch, b.result.Duration)
+	}
+	b.results = append(b.results, r)
+	b.rows = nil
+	b.batch = nil
+}
+
+// printName prints the name of b, after the names of the
+// benchmarks it is a sub-benchmark of, before b runs. Names
+// are printed lazily so benchmarks whose sub-benchmarks
+// are all skipped by Options.Bench aren't printed.
+func (b *B) printName() {
+	if b.named || b.format == FormatBench {
+		return
+	}
+	if b.parent != nil {
+		b.parent.printName()
+	}
+	fmt.Fprintln(b.output(), b.name)
+	b.named = true
+}
+
+func (b *B) output() io.Writer {
+	if b.out == nil {
+		return os.Stdout
+	}
+	return b.out
+}
+
+func (b *B) context() context.Context {
+	if b.ctx == nil {
+		return context.Background()
+	}
+	return b.ctx
+}
+
 func (b *B) numberOfRuns() int {
 	if b.n == 0 {
 		return defaultN
@@ -158,40 +640,169 @@ func (b *B) numberOfRuns() int {
 	return b.n
 }
 
-func (b *B) print() {
-	if histogram := histogram.NewHistogram(b.elapsed); histogram != nil {
-		fmt.Println("Latency histogram:")
-		fmt.Println(histogram)
+// Format is the output format of benchmark results.
+type Format string
+
+const (
+	// FormatText prints human-readable results
+	// with latency summaries and histograms.
+	FormatText Format = "text"
+
+	// FormatBench prints a line per benchmark run in
+	// the Go benchmark format, e.g.
+	//
+	//   BenchmarkReadOnly  50  312345678 ns/op  ...
+	//
+	// so results can be compared with benchstat.
+	FormatBench Format = "bench"
+)
+
+// Options configures how benchmarks are run and reported.
+type Options struct {
+	// Format is the output format. If not set,
+	// FormatText is used.
+	Format Format
+
+	// Output is where results are written. If nil,
+	// results are written to the standard output.
+	Output io.Writer
+
+	// Count is the number of times each benchmark
+	// is run. If zero, benchmarks are run once.
+	Count int
+
+	// Bench is a regular expression that selects the
+	// benchmarks to run by name, like go test -bench.
+	// Use slashes to select sub-benchmarks, e.g.
+	// "ReadOnly/limit=10". Benchmarks whose sub-benchmarks
+	// are all skipped have no result. If empty, all
+	// benchmarks run.
+	Bench string
+
+	// Disposable marks the database as safe to modify
+	// destructively. It is required to run benchmarks
+	// with RunPartitionedUpdate.
+	Disposable bool
+}
+
+// RegisterFlags registers the -bench, -count and -format
+// flags in fs to set the options from the command line.
+// Call it before fs is parsed.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Bench, "bench", o.Bench, "run only benchmarks matching the regular expression")
+	fs.IntVar(&o.Count, "count", o.Count, "run each benchmark `n` times")
+	fs.Var((*formatFlag)(&o.Format), "format", "output format, text or bench")
+}
+
+// formatFlag is a flag.Value for Format.
+type formatFlag Format
+
+func (f *formatFlag) String() string {
+	return string(*f)
+}
+
+func (f *formatFlag) Set(s string) error {
+	switch Format(s) {
+	case FormatText, FormatBench:
+		*f = formatFlag(s)
+		return nil
 	}
+	return fmt.Errorf("unknown format %q", s)
 }
 
 // Benchmark starts the benchmarks.
 // Provide the full-identifier of the Google Cloud Spanner
 // database as db.
-func Benchmark(db string, fn ...func(b *B)) {
-	ctx := context.Background()
+//
+// Results are printed as benchmarks run and are returned
+// in the order of fn. A benchmark run with TimestampBounds
+// returns a result for each bound, and a benchmark with
+// sub-benchmarks returns a result for each of them.
+func Benchmark(db string, fn ...func(b *B)) []Result {
+	return BenchmarkContext(context.Background(), db, fn...)
+}
+
+// BenchmarkContext is like Benchmark but runs the benchmarks
+// with ctx. Once ctx is done, the running benchmark stops and
+// the remaining benchmarks are reported as failed with the
+// context error. Use context.WithTimeout to bound the whole run.
+func BenchmarkContext(ctx context.Context, db string, fn ...func(b *B)) []Result {
+	return BenchmarkWithOptions(ctx, db, Options{}, fn...)
+}
+
+// BenchmarkWithOptions is like BenchmarkContext but reports
+// results as configured by opts. If opts.Count is larger than
+// one, each benchmark is run multiple times and a result is
+// returned for each run.
+func BenchmarkWithOptions(ctx context.Context, db string, opts Options, fn ...func(b *B)) []Result {
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	count := opts.Count
+	if count < 1 {
+		count = 1
+	}
+	match, err := newMatcher(opts.Bench)
+	if err != nil {
+		log.Fatalf("Invalid benchmark pattern %q: %v", opts.Bench, err)
+	}
+
+	results := make([]Result, 0, len(fn)*count)
 	for _, f := range fn {
-		// Don't reshare the same client between benchmarks.
-		client, err := spanner.NewClient(ctx, db, option.WithUserAgent(userAgent))
-		if err != nil {
-			log.Fatalf("Cannot create Spanner client: %v", err)
+		name := funcName(f)
+		if !match.match(0, name) {
+			continue
 		}
+		for i := 0; i < count; i++ {
+			if err := ctx.Err(); err != nil {
+				results = append(results, Result{Name: name, Err: err})
+				continue
+			}
 
-		name := funcName(f)
-		fmt.Println(name)
-		f(&B{
-			client: client,
-		})
+			b := &B{
+				ctx:        ctx,
+				name:       name,
+				out:        out,
+				format:     opts.Format,
+				disposable: opts.Disposable,
+				match:      match,
+			}
+			b.run(db, f)
+			if len(b.results) == 0 && !b.filtered {
+				b.printName()
+				b.results = append(b.results, newResult(name, nil, nil)) // benchmark didn't run
+			}
+			results = append(results, b.results...)
+		}
 	}
+	if len(results) == 0 && len(fn) > 0 {
+		log.Printf("No benchmarks match -bench %q", opts.Bench)
+	}
+	printFailures(out, results)
+	return results
 }
 
-func funcName(fn func(b *B)) string {
-	fullname := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
-	name := strings.Split(fullname, ".")
-	if len(name) == 2 {
-		return name[1]
+// run calls f with a new client, which is closed even
+// if f doesn't return normally.
+func (b *B) run(db string, f func(b *B)) {
+	// Don't reshare the same client between benchmarks.
+	client, err := spanner.NewClient(b.context(), db, option.WithUserAgent(userAgent))
+	if err != nil {
+		log.Fatalf("Cannot create Spanner client: %v", err)
 	}
-	return fullname // Anonymous functions.
+	defer client.Close()
+	b.client = client
+	f(b)
+}
+
+func funcName(fn func(b *B)) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	// Trim the import path and the package name, e.g.
+	// "example.com/bench.benchmarkReads" or, for closures,
+	// "example.com/bench.main.func1".
+	name = name[strings.LastIndex(name, "/")+1:]
+	return name[strings.Index(name, ".")+1:]
 }
 
 const (
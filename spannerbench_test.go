@@ -0,0 +1,120 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerbench
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestBoundName(t *testing.T) {
+	ts := time.Date(2020, 6, 1, 10, 0, 0, 500, time.UTC)
+	tests := []struct {
+		tb   spanner.TimestampBound
+		want string
+	}{
+		{spanner.StrongRead(), "strong"},
+		{spanner.ExactStaleness(15 * time.Second), "exactStaleness=15s"},
+		{spanner.MaxStaleness(500 * time.Millisecond), "maxStaleness=500ms"},
+		{spanner.ReadTimestamp(ts), "readTimestamp=2020-06-01T10:00:00.0000005Z"},
+		{spanner.MinReadTimestamp(ts), "minReadTimestamp=2020-06-01T10:00:00.0000005Z"},
+		{spanner.ReadTimestamp(ts.In(time.FixedZone("JST", 9*3600))), "readTimestamp=2020-06-01T19:00:00.0000005+09:00"},
+	}
+	for _, tt := range tests {
+		if got := boundName(tt.tb); got != tt.want {
+			t.Errorf("boundName(%v) = %q; want %q", tt.tb, got, tt.want)
+		}
+	}
+}
+
+func TestBoundNameMonotonic(t *testing.T) {
+	now := time.Now()
+	want := "readTimestamp=" + now.Format(time.RFC3339Nano)
+	if got := boundName(spanner.ReadTimestamp(now)); got != want {
+		t.Errorf("boundName() = %q; want %q", got, want)
+	}
+}
+
+func TestIsBounded(t *testing.T) {
+	ts := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		tb   spanner.TimestampBound
+		want bool
+	}{
+		{spanner.StrongRead(), false},
+		{spanner.ExactStaleness(15 * time.Second), false},
+		{spanner.ReadTimestamp(ts), false},
+		{spanner.MaxStaleness(15 * time.Second), true},
+		{spanner.MinReadTimestamp(ts), true},
+	}
+	for _, tt := range tests {
+		if got := isBounded(tt.tb); got != tt.want {
+			t.Errorf("isBounded(%v) = %v; want %v", tt.tb, got, tt.want)
+		}
+	}
+}
+
+func TestSubFiltered(t *testing.T) {
+	tests := []struct {
+		pattern      string
+		want         []string // result names
+		wantOutput   bool
+		wantFiltered bool
+	}{
+		{"bench", []string{"bench/a", "bench/b/c"}, true, false},
+		{"bench/a", []string{"bench/a"}, true, true},
+		// Benchmarks whose sub-benchmarks are all skipped
+		// aren't printed and have no results.
+		{"bench/z", nil, false, true},
+		{"bench/b/z", nil, false, true},
+	}
+	for _, tt := range tests {
+		m, err := newMatcher(tt.pattern)
+		if err != nil {
+			t.Fatalf("newMatcher(%q) = %v", tt.pattern, err)
+		}
+		var buf bytes.Buffer
+		b := &B{name: "bench", out: &buf, match: m}
+		b.Sub("a", func(b *B) {
+			// Fails without a client as the database isn't disposable.
+			b.RunPartitionedUpdate(spanner.NewStatement("DELETE FROM T WHERE true"))
+		})
+		b.Sub("b", func(b *B) {
+			b.Sub("c", func(b *B) {
+				b.RunPartitionedUpdate(spanner.NewStatement("DELETE FROM T WHERE true"))
+			})
+		})
+		var got []string
+		for _, r := range b.results {
+			got = append(got, r.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("-bench %q: results %q; want %q", tt.pattern, got, tt.want)
+		}
+		if tt.wantOutput && !strings.HasPrefix(buf.String(), "bench\nbench/a\n") {
+			t.Errorf("-bench %q: output %q; want the names of bench and bench/a first", tt.pattern, buf.String())
+		}
+		if !tt.wantOutput && buf.Len() > 0 {
+			t.Errorf("-bench %q: output %q; want none", tt.pattern, buf.String())
+		}
+		if b.filtered != tt.wantFiltered {
+			t.Errorf("-bench %q: filtered = %v; want %v", tt.pattern, b.filtered, tt.wantFiltered)
+		}
+	}
+}